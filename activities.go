@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Activity is one entry of an account's activity log.
+type Activity struct {
+	Date     int64  `json:"Date"`
+	User     string `json:"User"`
+	Action   string `json:"Action"`
+	ActionID int    `json:"ActionID"`
+	Reason   string `json:"Reason"`
+	MoreInfo string `json:"MoreInfo"`
+	ClientID string `json:"ClientID"`
+	Alert    bool   `json:"Alert"`
+}
+
+// Time returns the activity's timestamp.
+func (a Activity) Time() time.Time {
+	return time.Unix(a.Date, 0).UTC()
+}
+
+// getActivities returns the activity log of an account.
+func getActivities(client *APIClient, accountID string) ([]Activity, error) {
+	result, err := GetInto[struct {
+		Activities []Activity `json:"Activities"`
+	}](client, "PasswordVault/API/Accounts/"+url.PathEscape(accountID)+"/Activities")
+	if err != nil {
+		return nil, err
+	}
+	return result.Activities, nil
+}
+
+// dateLayouts are the formats accepted by date range flags.
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+// parseDate parses a date flag. A plain date with endOfDay set is taken as
+// the last moment of that day, so "--to 2024-03-31" includes March 31st.
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		t, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			continue
+		}
+		if layout == "2006-01-02" && endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or RFC 3339", value)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+)
+
+// auditEntry is one line of the audit log.
+type auditEntry struct {
+	Time     string `json:"time"`
+	User     string `json:"user"`
+	OSUser   string `json:"os_user,omitempty"`
+	Workflow string `json:"workflow"`
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"`
+	Status   int    `json:"status,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// auditLog appends a JSON line per request to a local file. It records
+// where requests went and how they ended, never their bodies, so secrets
+// sent to the API do not end up in the log.
+type auditLog struct {
+	mu     sync.Mutex
+	f      *os.File
+	osUser string
+	reads  bool
+}
+
+// openAuditLog opens path for appending, creating it with mode 0600. Reads
+// are only logged when reads is set.
+func openAuditLog(path string, reads bool) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if info, err := f.Stat(); err == nil {
+		if err := checkPrivate(path, info, "audit log"); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	l := &auditLog{f: f, reads: reads}
+	if u, err := user.Current(); err == nil {
+		l.osUser = u.Username
+	}
+	return l, nil
+}
+
+// record logs the outcome of a request. A failure to write is reported on
+// stderr rather than failing the request, which has already been made.
+func (l *auditLog) record(c *APIClient, method, endpoint string, resp *http.Response, err error) {
+	if l == nil {
+		return
+	}
+	if !l.reads && (method == http.MethodGet || method == http.MethodHead) {
+		return
+	}
+	entry := auditEntry{
+		Time:     time.Now().UTC().Format(time.RFC3339),
+		User:     c.config.Username,
+		OSUser:   l.osUser,
+		Workflow: c.workflow,
+		Method:   method,
+		Endpoint: endpoint,
+	}
+	if resp != nil {
+		entry.Status = resp.StatusCode
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	line, _ := json.Marshal(entry)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, werr := l.f.Write(append(line, '\n')); werr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write audit log: %v\n", werr)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// auditedClient is a test client that audits to a file in a temporary
+// directory, returned with the client.
+func auditedClient(t *testing.T, srv *httptest.Server, reads bool) (*APIClient, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	client, err := NewAPIClient(&Config{BaseURL: srv.URL, Username: "svc", APISecret: "secret", Timeout: 5, AuditLogPath: path, AuditReads: reads})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.workflow = "test"
+	return client, path
+}
+
+// readAudit returns the entries written to the audit log at path.
+func readAudit(t *testing.T, path string) []auditEntry {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []auditEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "" {
+			continue
+		}
+		var e auditEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("audit line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAuditLogRecordsChangesAndOptionallyReads(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	for _, reads := range []bool{false, true} {
+		client, path := auditedClient(t, srv, reads)
+		if _, err := client.Get("PasswordVault/API/Safes"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Post("PasswordVault/API/Safes", map[string]string{"safeName": "Linux"}); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range readAudit(t, path) {
+			if e.User != "svc" || e.Workflow != "test" || e.Status != http.StatusOK {
+				t.Errorf("audit entry %+v, want user, workflow and status recorded", e)
+			}
+			got = append(got, e.Method+" "+e.Endpoint)
+		}
+		want := "POST PasswordVault/API/Safes"
+		if reads {
+			want = "GET PasswordVault/API/Safes,POST PasswordVault/API/Safes"
+		}
+		if strings.Join(got, ",") != want {
+			t.Errorf("with reads=%v, audited %v, want %s", reads, got, want)
+		}
+		data, _ := os.ReadFile(path)
+		if strings.Contains(string(data), "Linux") {
+			t.Error("the audit log contains a request body")
+		}
+	}
+}
+
+func TestAuditLogRecordsARetryCutShort(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	client, path := auditedClient(t, srv, false)
+	client.MaxRetries, client.RetryBaseDelay = 3, time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := client.send(ctx, http.MethodDelete, "PasswordVault/API/Accounts/12_3", nil); err == nil {
+		t.Fatal("send succeeded, want it aborted while waiting to retry")
+	}
+	entries := readAudit(t, path)
+	if len(entries) != 1 || entries[0].Status != http.StatusServiceUnavailable || !strings.Contains(entries[0].Error, "aborted") {
+		t.Errorf("audit = %+v, want the attempt made and why it was not retried", entries)
+	}
+}
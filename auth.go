@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// radiusChallengeCode is the error code the PVWA returns from Logon when
+// the authentication server needs a further response, such as an OTP.
+const radiusChallengeCode = "ITATS542I"
+
+// maxAuthChallenges bounds the challenge/response rounds in one Logon.
+const maxAuthChallenges = 3
+
+// logonRequest is the body of POST /Auth/{method}/Logon. Windows
+// authentication can leave both fields out, since the user is identified
+// by the connection.
+type logonRequest struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Logon authenticates with the configured username and API secret, using
+// the configured authentication method, and stores the returned session
+// token, which then authorizes all further requests. If the server answers
+// with a challenge, AuthPrompt is asked for the response, which is sent in
+// place of the password.
+func (c *APIClient) Logon() (string, error) {
+	endpoint := "PasswordVault/API/Auth/" + c.config.authMethodName() + "/Logon"
+	body := logonRequest{Username: c.config.Username, Password: c.config.APISecret}
+
+	for round := 0; ; round++ {
+		resp, respBody, err := c.sendAs(c.baseContext(), http.MethodPost, endpoint, body, "")
+		if err != nil {
+			return "", err
+		}
+		// Some RADIUS setups send the challenge with a 200 status, so look
+		// for one before treating the response as a session.
+		challenge, isChallenge := authChallenge(respBody)
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 && !isChallenge {
+			if c.config.SessionCookie {
+				return c.cookieLogon(respBody)
+			}
+			token, err := parseToken(respBody)
+			if err != nil {
+				return "", err
+			}
+			c.token = token
+			if c.cache != nil {
+				if err := c.cache.save(c.config, token); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				}
+			}
+			return token, nil
+		}
+
+		if !isChallenge {
+			return "", fmt.Errorf("logon failed: %w", newAPIError(resp.StatusCode, respBody))
+		}
+		if round == maxAuthChallenges {
+			return "", errors.New("logon failed: too many authentication challenges")
+		}
+		if c.AuthPrompt == nil {
+			return "", fmt.Errorf("logon requires a response to the challenge %q", challenge)
+		}
+		answer, err := c.AuthPrompt(challenge)
+		if err != nil {
+			return "", fmt.Errorf("logon challenge: %w", err)
+		}
+		body.Password = answer
+	}
+}
+
+// Logoff ends the session started by Logon and forgets its token and
+// cookies. It does nothing when there is no session.
+func (c *APIClient) Logoff() error {
+	if c.token == "" && !c.cookieSession {
+		return nil
+	}
+	// Log off even when the run was interrupted, so the session does not
+	// linger until it times out on the server.
+	_, err := c.PostContext(context.WithoutCancel(c.baseContext()), "PasswordVault/API/Auth/Logoff", nil)
+	c.token, c.cookieSession = "", false
+	if c.httpClient.Jar != nil {
+		// A fresh jar is the only way to drop every cookie.
+		jar, jerr := cookiejar.New(nil)
+		if jerr != nil {
+			return jerr
+		}
+		c.httpClient.Jar = jar
+	}
+	if err != nil {
+		return fmt.Errorf("logoff failed: %w", err)
+	}
+	return nil
+}
+
+// cookieLogon completes a Logon in session_cookie mode. The session
+// cookie is already in the jar; a token in the body is used as well when
+// the server returns one. Only a JSON string counts as a token here, since
+// proxies in this setup often answer with a body of their own.
+func (c *APIClient) cookieLogon(body []byte) (string, error) {
+	base, err := url.Parse(c.config.BaseURL)
+	if err != nil {
+		return "", err
+	}
+	if len(c.httpClient.Jar.Cookies(base)) == 0 {
+		return "", errors.New("logon succeeded but the server set no session cookie; is session_cookie needed for this PVWA?")
+	}
+	c.cookieSession = true
+	var token string
+	if json.Unmarshal(body, &token) == nil {
+		c.token = token
+	}
+	return c.token, nil
+}
+
+// authChallenge reports whether a Logon response is a challenge and
+// returns its message. The challenge comes in the vault's error body,
+// whatever the status code.
+func authChallenge(body []byte) (string, bool) {
+	e := newAPIError(0, body)
+	if e.ErrorCode != radiusChallengeCode {
+		return "", false
+	}
+	return e.Message, true
+}
+
+// parseToken extracts the session token from a Logon response, which is
+// a JSON string.
+func parseToken(body []byte) (string, error) {
+	var token string
+	if err := json.Unmarshal(body, &token); err != nil {
+		token = strings.TrimSpace(string(body))
+	}
+	if token == "" {
+		return "", errors.New("logon succeeded but no session token was returned")
+	}
+	return token, nil
+}
+
+// fixedAuthResponse returns an AuthPrompt that answers the first challenge
+// with response, for one-time passcodes given on the command line or in
+// the environment. A further challenge means the passcode was refused.
+func fixedAuthResponse(response string) func(string) (string, error) {
+	used := false
+	return func(challenge string) (string, error) {
+		if used {
+			return "", fmt.Errorf("the one-time passcode was not accepted; the server asked again: %s", strings.TrimSpace(challenge))
+		}
+		used = true
+		return response, nil
+	}
+}
+
+// terminalAuthPrompt shows the challenge on stderr and reads the response
+// from the terminal without echoing it.
+func terminalAuthPrompt(challenge string) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", errors.New("an authentication challenge needs a response but stdin is not a terminal")
+	}
+	// A passcode never has spaces of its own, unlike the passwords
+	// askSecret also reads.
+	answer, err := askSecret(strings.TrimSpace(challenge) + ": ")
+	return strings.TrimSpace(answer), err
+}
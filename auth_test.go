@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLogonAnswersChallengeWithAuthPrompt(t *testing.T) {
+	var logons []logonRequest
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PasswordVault/API/Auth/CyberArk/Logon":
+			var body logonRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decode logon body: %v", err)
+			}
+			logons = append(logons, body)
+			if body.Password != "123456" {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"ErrorCode":"ITATS542I","ErrorMessage":"Enter your one-time passcode"}`))
+				return
+			}
+			w.Write([]byte(`"session-token"`))
+		default:
+			gotAuth = r.Header.Get("Authorization")
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewAPIClient(&Config{BaseURL: srv.URL, Username: "svc", APISecret: "password", Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var challenges []string
+	client.AuthPrompt = func(challenge string) (string, error) {
+		challenges = append(challenges, challenge)
+		return "123456", nil
+	}
+
+	token, err := client.Logon()
+	if err != nil {
+		t.Fatalf("Logon() error = %v", err)
+	}
+	if token != "session-token" {
+		t.Errorf("token = %q, want %q", token, "session-token")
+	}
+	if len(challenges) != 1 || challenges[0] != "Enter your one-time passcode" {
+		t.Errorf("prompt calls = %q, want one with the server's challenge", challenges)
+	}
+	if len(logons) != 2 || logons[0].Password != "password" || logons[1].Password != "123456" {
+		t.Errorf("logon requests = %+v, want the password followed by the OTP", logons)
+	}
+
+	if _, err := client.Get("PasswordVault/API/Safes"); err != nil {
+		t.Fatal(err)
+	}
+	if gotAuth != "session-token" {
+		t.Errorf("Authorization after Logon = %q, want the session token", gotAuth)
+	}
+}
+
+func TestLogonWithoutPromptFailsOnChallenge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"ErrorCode":"ITATS542I","ErrorMessage":"Enter your one-time passcode"}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewAPIClient(&Config{BaseURL: srv.URL, Username: "svc", APISecret: "password", Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AuthPrompt = nil
+	if _, err := client.Logon(); err == nil {
+		t.Fatal("Logon() succeeded without a way to answer the challenge")
+	}
+}
+
+func TestLogonAnswersChallengeSentWithOKStatus(t *testing.T) {
+	var passwords []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body logonRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		passwords = append(passwords, body.Password)
+		if len(passwords) == 1 {
+			w.Write([]byte(`{"ErrorCode":"ITATS542I","ErrorMessage":"Enter your one-time passcode"}`))
+			return
+		}
+		w.Write([]byte(`"session-token"`))
+	}))
+	defer srv.Close()
+
+	client, err := NewAPIClient(&Config{BaseURL: srv.URL, Username: "svc", APISecret: "password", AuthMethod: "radius", Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AuthPrompt = fixedAuthResponse("123456")
+	token, err := client.Logon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "session-token" {
+		t.Errorf("Logon() = %q, want the session token", token)
+	}
+	if len(passwords) != 2 || passwords[1] != "123456" {
+		t.Errorf("passwords sent = %q, want the secret then the passcode", passwords)
+	}
+}
+
+func TestFixedAuthResponseAnswersOnce(t *testing.T) {
+	prompt := fixedAuthResponse("123456")
+	if got, err := prompt("Enter your one-time passcode"); err != nil || got != "123456" {
+		t.Fatalf("first challenge = %q, %v; want the passcode", got, err)
+	}
+	if _, err := prompt("Enter your one-time passcode"); err == nil {
+		t.Error("second challenge was answered with the same passcode")
+	}
+}
+
+func TestLogonWithSessionCookie(t *testing.T) {
+	var gotCookie, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PasswordVault/API/Auth/CyberArk/Logon":
+			http.SetCookie(w, &http.Cookie{Name: "CASession", Value: "cookie-token", Path: "/"})
+			w.Write([]byte(`{"status":"ok"}`))
+		default:
+			if c, err := r.Cookie("CASession"); err == nil {
+				gotCookie = c.Value
+			}
+			gotAuth = r.Header.Get("Authorization")
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewAPIClient(&Config{BaseURL: srv.URL, Username: "svc", APISecret: "password", Timeout: 5, SessionCookie: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logon(); err != nil {
+		t.Fatalf("Logon() error = %v", err)
+	}
+	if _, err := client.Get("PasswordVault/API/Safes"); err != nil {
+		t.Fatal(err)
+	}
+	if gotCookie != "cookie-token" {
+		t.Errorf("session cookie after Logon = %q, want %q", gotCookie, "cookie-token")
+	}
+	if gotAuth != "" {
+		t.Errorf("Authorization after cookie Logon = %q, want none", gotAuth)
+	}
+}
+
+func TestLogonWithSessionCookieFailsWithoutCookie(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`"session-token"`))
+	}))
+	defer srv.Close()
+
+	client, err := NewAPIClient(&Config{BaseURL: srv.URL, Username: "svc", APISecret: "password", Timeout: 5, SessionCookie: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logon(); err == nil {
+		t.Fatal("Logon() succeeded although no session cookie was set")
+	}
+}
+
+func TestLogoffEndsSession(t *testing.T) {
+	var logoffAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PasswordVault/API/Auth/CyberArk/Logon":
+			w.Write([]byte(`"session-token"`))
+		case "/PasswordVault/API/Auth/Logoff":
+			logoffAuth = r.Header.Get("Authorization")
+			w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewAPIClient(&Config{BaseURL: srv.URL, Username: "svc", APISecret: "password", Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logon(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Logoff(); err != nil {
+		t.Fatalf("Logoff() error = %v", err)
+	}
+	if logoffAuth != "session-token" {
+		t.Errorf("Logoff Authorization = %q, want the session token", logoffAuth)
+	}
+	if client.token != "" {
+		t.Errorf("token after Logoff = %q, want it cleared", client.token)
+	}
+}
+
+func TestExpiredCachedTokenLogsOnAgain(t *testing.T) {
+	var logons int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/PasswordVault/API/Auth/CyberArk/Logon":
+			logons++
+			w.Write([]byte(`"fresh-token"`))
+		case r.Header.Get("Authorization") != "fresh-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	config := &Config{BaseURL: srv.URL, Username: "svc", APISecret: "password", Timeout: 5}
+	client, err := NewAPIClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.cache = &tokenCache{path: filepath.Join(t.TempDir(), "token"), ttl: time.Hour}
+	client.token, client.cachedToken = "stale-token", true
+
+	if _, err := client.Get("PasswordVault/API/Safes"); err != nil {
+		t.Fatalf("Get() with an expired cached token = %v, want a transparent logon", err)
+	}
+	if logons != 1 {
+		t.Errorf("logons = %d, want 1", logons)
+	}
+	if token, ok := client.cache.load(config); !ok || token != "fresh-token" {
+		t.Errorf("cached token = %q, %t; want the fresh token", token, ok)
+	}
+}
+
+func TestRefreshSessionLogsOnOnceForConcurrentRequests(t *testing.T) {
+	var logons atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/PasswordVault/API/Auth/CyberArk/Logon":
+			logons.Add(1)
+			w.Write([]byte(`"fresh-token"`))
+		case r.Header.Get("Authorization") != "fresh-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	config := &Config{BaseURL: srv.URL, Username: "svc", APISecret: "password", Timeout: 5}
+	client, err := NewAPIClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.token = "expired-token"
+	if _, err := client.Get("PasswordVault/API/Safes"); apiStatus(err) != http.StatusUnauthorized {
+		t.Fatalf("Get() without refresh_session = %v, want the 401", err)
+	}
+
+	config.RefreshSession = true
+	errs := runConcurrent(context.Background(), make([]int, 8), 8, func(int) error {
+		_, err := client.Get("PasswordVault/API/Safes")
+		return err
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("Get() with an expired session = %v, want a transparent logon", err)
+		}
+	}
+	if n := logons.Load(); n != 1 {
+		t.Errorf("logons = %d, want 1", n)
+	}
+}
+
+func TestRefreshSessionLogsOnOnceWithSessionCookie(t *testing.T) {
+	const workers = 8
+	var logons atomic.Int32
+	var refused sync.WaitGroup
+	refused.Add(workers)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/PasswordVault/API/Auth/CyberArk/Logon" {
+			n := logons.Add(1)
+			http.SetCookie(w, &http.Cookie{Name: "CASession", Value: fmt.Sprint(n), Path: "/"})
+			w.Write([]byte(`""`))
+			return
+		}
+		if c, err := r.Cookie("CASession"); err != nil || c.Value == "1" {
+			// Hold every refusal until all workers have been refused, so
+			// they all see the first session expire together.
+			refused.Done()
+			refused.Wait()
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewAPIClient(&Config{BaseURL: srv.URL, Username: "svc", APISecret: "password", Timeout: 5, SessionCookie: true, RefreshSession: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logon(); err != nil {
+		t.Fatal(err)
+	}
+	errs := runConcurrent(context.Background(), make([]int, workers), workers, func(int) error {
+		_, err := client.Get("PasswordVault/API/Safes")
+		return err
+	})
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("Get() with an expired cookie session = %v, want a transparent logon", err)
+		}
+	}
+	if n := logons.Load(); n != 2 {
+		t.Errorf("logons = %d, want the first and one shared re-logon", n)
+	}
+}
+
+func TestRefreshSessionRetriesOnlyOnce(t *testing.T) {
+	var logons, requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/PasswordVault/API/Auth/CyberArk/Logon" {
+			logons++
+			w.Write([]byte(`"fresh-token"`))
+			return
+		}
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	config := &Config{BaseURL: srv.URL, Username: "svc", APISecret: "password", Timeout: 5, RefreshSession: true}
+	client, err := NewAPIClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.token = "expired-token"
+	if _, err := client.Get("PasswordVault/API/Safes"); apiStatus(err) != http.StatusUnauthorized {
+		t.Fatalf("Get() = %v, want the 401 from the retried request", err)
+	}
+	if logons != 1 || requests != 2 {
+		t.Errorf("logons = %d, requests = %d; want 1 and 2", logons, requests)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CCPConfig holds the settings for the Central Credential Provider web
+// service used by ccp-get. Applications authenticate to it by AppID and,
+// commonly, a client certificate rather than with a vault session.
+type CCPConfig struct {
+	// URL is the CCP host; base_url is used when it is empty.
+	URL   string `json:"url,omitempty"`
+	AppID string `json:"app_id,omitempty"`
+
+	// CertPath and KeyPath are a PEM client certificate and its key for
+	// mutual TLS with the CCP, used in place of client_cert_path.
+	CertPath string `json:"cert_path,omitempty"`
+	KeyPath  string `json:"key_path,omitempty"`
+}
+
+func (c *CCPConfig) validate() error {
+	if (c.CertPath == "") != (c.KeyPath == "") {
+		return errors.New("config: ccp.cert_path and ccp.key_path must be set together")
+	}
+	return nil
+}
+
+// CCPRequest names the account to retrieve. Either Object or Query selects
+// it; Reason is recorded in the vault's audit for this retrieval.
+type CCPRequest struct {
+	AppID  string
+	Safe   string
+	Folder string
+	Object string
+	Query  string
+	Reason string
+}
+
+// CCPAccount is the part of a CCP response ccp-get uses.
+type CCPAccount struct {
+	Content  string `json:"Content"`
+	UserName string `json:"UserName"`
+	Address  string `json:"Address"`
+	Name     string `json:"Name"`
+}
+
+// ccpErrorHints explains the CCP error codes users most often hit.
+var ccpErrorHints = map[string]string{
+	"APPAP004E": "no account matches the query",
+	"APPAP227E": "more than one account matches the query; narrow it down",
+	"APPAP282E": "the application could not be authenticated; check the AppID and that this machine or certificate is allowed for it",
+	"AIMWS030E": "the request is invalid; check the AppID and query parameters",
+	"AIMWS031E": "the query is invalid; it must be Key=Value pairs separated by semicolons",
+}
+
+// CCPError is an error response from the Central Credential Provider,
+// which uses ErrorMsg where the PVWA uses ErrorMessage.
+type CCPError struct {
+	StatusCode int
+	ErrorCode  string
+	Message    string
+}
+
+func (e *CCPError) Error() string {
+	if e.ErrorCode == "" {
+		return fmt.Sprintf("CCP error (status %d): %s", e.StatusCode, e.Message)
+	}
+	if hint, ok := ccpErrorHints[e.ErrorCode]; ok {
+		return fmt.Sprintf("%s (%s: %s)", hint, e.ErrorCode, e.Message)
+	}
+	return fmt.Sprintf("CCP error (status %d, %s): %s", e.StatusCode, e.ErrorCode, e.Message)
+}
+
+// CCPClient retrieves passwords from the Central Credential Provider.
+type CCPClient struct {
+	baseURL    string
+	httpClient *http.Client
+
+	// ctx, requestLog and timings are the PVWA client's, so interrupts,
+	// --deadline, --verbose and --timing cover CCP requests too.
+	ctx        context.Context
+	requestLog *requestLogger
+	timings    *requestTimings
+}
+
+// NewCCPClient returns a CCP client for baseURL that sends requests through
+// transport, with the PVWA client's timeout, context and request tracing.
+func NewCCPClient(baseURL string, transport http.RoundTripper, client *APIClient) *CCPClient {
+	return &CCPClient{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Transport: transport, Timeout: client.timeout},
+		ctx:        client.baseContext(),
+		requestLog: client.requestLog,
+		timings:    client.timings,
+	}
+}
+
+// withClientCert returns a copy of transport that presents the client
+// certificate at certPath and keyPath. Transports other than
+// *http.Transport, such as the sandbox's, are returned unchanged.
+func withClientCert(transport http.RoundTripper, certPath, keyPath string) (http.RoundTripper, error) {
+	cert, err := loadClientCertificate(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		return transport, nil
+	}
+	t = t.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	t.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	return t, nil
+}
+
+// GetAccount retrieves the account described by r.
+func (c *CCPClient) GetAccount(r CCPRequest) (*CCPAccount, error) {
+	q := url.Values{"AppID": {r.AppID}}
+	for name, value := range map[string]string{"Safe": r.Safe, "Folder": r.Folder, "Object": r.Object, "Query": r.Query, "Reason": r.Reason} {
+		if value != "" {
+			q.Set(name, value)
+		}
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, withQuery(joinURL(c.baseURL, "AIMWebService/api/Accounts"), q), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	var body []byte
+	if err == nil {
+		body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			err = fmt.Errorf("failed to read response: %w", err)
+		}
+	} else {
+		err = fmt.Errorf("%w: %w", errTransport, err)
+	}
+	elapsed := time.Since(start)
+	// The password comes back in Content, which the trace redacts.
+	c.requestLog.record(req, nil, resp, body, err, elapsed)
+	c.timings.record(req, elapsed)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		ccpErr := &CCPError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+		var envelope struct {
+			ErrorCode string `json:"ErrorCode"`
+			ErrorMsg  string `json:"ErrorMsg"`
+		}
+		if json.Unmarshal(body, &envelope) == nil && envelope.ErrorCode != "" {
+			ccpErr.ErrorCode, ccpErr.Message = envelope.ErrorCode, envelope.ErrorMsg
+		}
+		return nil, ccpErr
+	}
+	var account CCPAccount
+	if err := json.Unmarshal(body, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse CCP response: %w", err)
+	}
+	return &account, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCCPGetAccount(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/AIMWebService/api/Accounts" || q.Get("AppID") != "billing" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if q.Get("Object") == "db01" {
+			w.Write([]byte(`{"Content": "s3cret", "UserName": "svc"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"ErrorCode": "APPAP004E", "ErrorMsg": "Password object matching query [Safe=Ops] was not found"}`))
+	}))
+	defer srv.Close()
+
+	ccp := NewCCPClient(srv.URL, srv.Client().Transport, newTestClient(t, srv))
+	account, err := ccp.GetAccount(CCPRequest{AppID: "billing", Safe: "Ops", Object: "db01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Content != "s3cret" {
+		t.Errorf("Content = %q, want s3cret", account.Content)
+	}
+
+	_, err = ccp.GetAccount(CCPRequest{AppID: "billing", Safe: "Ops", Object: "db02"})
+	if err == nil || !strings.HasPrefix(err.Error(), "no account matches the query (APPAP004E") {
+		t.Errorf("error = %v, want the APPAP004E hint", err)
+	}
+}
+
+func TestCCPRequestsAreTracedAndCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Content": "s3cret", "UserName": "svc"}`))
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	var trace strings.Builder
+	client.requestLog = &requestLogger{w: &trace, bodies: true}
+	request := CCPRequest{AppID: "billing", Safe: "Ops", Object: "db01"}
+	if _, err := NewCCPClient(srv.URL, srv.Client().Transport, client).GetAccount(request); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(trace.String(), "s3cret") || !strings.Contains(trace.String(), "AIMWebService/api/Accounts") {
+		t.Errorf("trace = %q, want the request with its password redacted", trace.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.ctx = ctx
+	if _, err := NewCCPClient(srv.URL, srv.Client().Transport, client).GetAccount(request); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetAccount() after an interrupt = %v, want context.Canceled", err)
+	}
+}
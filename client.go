@@ -0,0 +1,618 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errTransport marks failures where no response was received at all, such
+// as refused connections, DNS failures and timeouts.
+var errTransport = errors.New("request failed")
+
+// errTruncated marks a response whose body was cut off, for example when
+// the connection dropped mid-transfer. It is always wrapped together with
+// errTransport, so truncated idempotent requests are retried.
+var errTruncated = errors.New("response truncated, the connection was interrupted")
+
+// APIClient performs authenticated requests against the PVWA REST API.
+type APIClient struct {
+	config     *Config
+	httpClient *http.Client
+
+	// timeout bounds each request, including reading the response body.
+	timeout time.Duration
+
+	// ctx, when set, bounds every request made through the methods that
+	// take no context, such as Get. run sets it so that --deadline and
+	// Ctrl-C abort the workflow's requests.
+	ctx context.Context
+
+	// workflow names the workflow the client was made for, for the audit
+	// log.
+	workflow string
+
+	// audit is the audit log, or nil when none is configured.
+	audit *auditLog
+
+	// limiter spaces requests out to the config's requests_per_second, or
+	// is nil when there is no limit.
+	limiter *rateLimiter
+
+	// requestLog traces requests on stderr for --verbose and --debug, or
+	// is nil.
+	requestLog *requestLogger
+
+	// timings collects request latencies for --timing, or is nil.
+	timings *requestTimings
+
+	// outputFormat is the global --output, which list workflows use as
+	// the default of their own --output.
+	outputFormat string
+
+	// dryRun makes requests that would change something print themselves
+	// instead of being sent; see the global --dry-run flag.
+	dryRun bool
+
+	// prechecks makes precheck verify a workflow's declared permissions
+	// before it starts; see the global --precheck flag.
+	prechecks bool
+
+	// certs holds the server certificate once a TLS connection is made.
+	certs *certWatch
+
+	// token is the session token from Logon. Until Logon succeeds,
+	// requests are authorized with the configured API secret.
+	token string
+
+	// cache, when set, saves the token from Logon for later runs.
+	// cachedToken is set while token is one loaded from the cache, which
+	// the server may have expired before its TTL.
+	cache       *tokenCache
+	cachedToken bool
+
+	// cookieSession is set once Logon has stored a session cookie in the
+	// client's cookie jar, which then authorizes requests in place of the
+	// API secret.
+	cookieSession bool
+
+	// sessionMu guards token, cachedToken, cookieSession and session
+	// while concurrent workers may log on again. It is a pointer so that
+	// the copies made by forOperation share it.
+	sessionMu *sync.Mutex
+
+	// session counts the Logons relogon has made, so that workers refused
+	// by the same expired session can tell whether one of them has
+	// already replaced it. A cookie session has no token to compare.
+	session uint64
+
+	// AuthPrompt is called by Logon when the server answers with a
+	// challenge, such as a RADIUS one-time passcode request, and returns
+	// the user's response. It defaults to prompting on the terminal;
+	// embedders can replace it to collect the response another way.
+	AuthPrompt func(challenge string) (string, error)
+
+	// MaxRetries is how many times a failed request is retried, waiting
+	// RetryBaseDelay before the first retry and twice as long before each
+	// further one. RetryPOST allows retrying POST requests too. They are
+	// set from the config and only apply when ShouldRetry is not set.
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryPOST      bool
+
+	// ShouldRetry, when set, replaces the default decision of whether to
+	// retry a request. It is called after every attempt with the response
+	// (whose body has already been read and closed) or the transport
+	// error, and the number of attempts made so far. The hook is
+	// responsible for stopping after a sensible number of attempts; the
+	// client imposes no limit of its own when it is set.
+	ShouldRetry func(resp *http.Response, err error, attempt int) bool
+
+	// Headers are set on every request after the standard ones, so they
+	// can also replace Accept or Content-Type.
+	Headers http.Header
+}
+
+// maxRetryDelay caps the wait between two attempts, including a wait the
+// server asks for with Retry-After.
+const maxRetryDelay = time.Minute
+
+// NewAPIClient returns a client for the vault described by config. It
+// fails if the TLS settings cannot be loaded.
+func NewAPIClient(config *Config) (*APIClient, error) {
+	certs := &certWatch{warnWithin: time.Duration(config.CertExpiryWarnDays) * 24 * time.Hour}
+	tlsConfig, err := buildTLSConfig(config, certs)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if config.Proxy != "" {
+		proxy, err := parseProxyURL(config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	client, err := NewAPIClientWithHTTPClient(config, &http.Client{Transport: transport})
+	if err != nil {
+		return nil, err
+	}
+	client.certs = certs
+	return client, nil
+}
+
+// NewAPIClientWithHTTPClient returns a client that sends its requests
+// through hc, such as one whose Transport is a test stub. The config's TLS
+// settings are not applied; they belong to hc's transport. The client
+// keeps hc, and gives it a cookie jar if session_cookie needs one.
+func NewAPIClientWithHTTPClient(config *Config, hc *http.Client) (*APIClient, error) {
+	var audit *auditLog
+	var err error
+	if config.AuditLogPath != "" {
+		if audit, err = openAuditLog(config.AuditLogPath, config.AuditReads); err != nil {
+			return nil, err
+		}
+	}
+	if config.SessionCookie && hc.Jar == nil {
+		if hc.Jar, err = cookiejar.New(nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return &APIClient{
+		config:         config,
+		httpClient:     hc,
+		timeout:        time.Duration(config.Timeout) * time.Second,
+		audit:          audit,
+		limiter:        newRateLimiter(config.RequestsPerSecond),
+		certs:          &certWatch{},
+		sessionMu:      &sync.Mutex{},
+		AuthPrompt:     terminalAuthPrompt,
+		MaxRetries:     config.maxRetries(),
+		RetryBaseDelay: config.retryBaseDelay(),
+		RetryPOST:      config.RetryPOST,
+	}, nil
+}
+
+// parseProxyURL checks the proxy setting. net/http would otherwise only
+// fail at the first request, with an error that does not mention the
+// proxy. Credentials are left out of the messages.
+func parseProxyURL(value string) (*url.URL, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, errors.New("config: proxy is not a valid URL")
+	}
+	switch {
+	case u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5":
+		return nil, fmt.Errorf("config: proxy %q must start with http://, https:// or socks5://", u.Redacted())
+	case u.Host == "":
+		return nil, fmt.Errorf("config: proxy %q has no host", u.Redacted())
+	}
+	return u, nil
+}
+
+// forOperation returns a copy of the client for the named workflow. Its
+// requests use the timeout configured for the workflow in the config's
+// timeouts map, or the global timeout if there is none.
+func (c *APIClient) forOperation(name string) *APIClient {
+	op := *c
+	op.workflow = name
+	if seconds, ok := c.config.Timeouts[name]; ok {
+		op.timeout = time.Duration(seconds) * time.Second
+	}
+	return &op
+}
+
+// baseContext returns the context requests without one of their own run
+// under.
+func (c *APIClient) baseContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Get issues a GET request to endpoint, which is relative to BaseURL.
+func (c *APIClient) Get(endpoint string) ([]byte, error) {
+	return c.GetWithParams(endpoint, nil)
+}
+
+// GetWithParams issues a GET request to endpoint with params encoded as
+// its query string.
+func (c *APIClient) GetWithParams(endpoint string, params url.Values) ([]byte, error) {
+	return c.GetWithParamsContext(c.baseContext(), endpoint, params)
+}
+
+// Post issues a POST request with payload encoded as JSON.
+func (c *APIClient) Post(endpoint string, payload interface{}) ([]byte, error) {
+	return c.PostContext(c.baseContext(), endpoint, payload)
+}
+
+// Put issues a PUT request with payload encoded as JSON.
+func (c *APIClient) Put(endpoint string, payload interface{}) ([]byte, error) {
+	return c.PutContext(c.baseContext(), endpoint, payload)
+}
+
+// Patch issues a PATCH request with payload encoded as JSON.
+func (c *APIClient) Patch(endpoint string, payload interface{}) ([]byte, error) {
+	return c.PatchContext(c.baseContext(), endpoint, payload)
+}
+
+// Delete issues a DELETE request to endpoint.
+func (c *APIClient) Delete(endpoint string) ([]byte, error) {
+	return c.DeleteContext(c.baseContext(), endpoint)
+}
+
+// GetContext is Get with a context that can cancel the request, including
+// any retries. The client's timeout still applies to each attempt.
+func (c *APIClient) GetContext(ctx context.Context, endpoint string) ([]byte, error) {
+	return c.GetWithParamsContext(ctx, endpoint, nil)
+}
+
+// GetWithParamsContext is GetWithParams with a context; see GetContext.
+func (c *APIClient) GetWithParamsContext(ctx context.Context, endpoint string, params url.Values) ([]byte, error) {
+	return c.doRequest(ctx, http.MethodGet, withQuery(endpoint, params), nil)
+}
+
+// withQuery appends params to endpoint's query string, if there are any.
+func withQuery(endpoint string, params url.Values) string {
+	if len(params) == 0 {
+		return endpoint
+	}
+	sep := "?"
+	if strings.Contains(endpoint, "?") {
+		sep = "&"
+	}
+	return endpoint + sep + params.Encode()
+}
+
+// joinURL joins the base URL and an endpoint with exactly one slash, so a
+// base URL ending in a slash neither doubles it nor drops a segment.
+func joinURL(base, endpoint string) string {
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(endpoint, "/")
+}
+
+// PostContext is Post with a context; see GetContext.
+func (c *APIClient) PostContext(ctx context.Context, endpoint string, payload interface{}) ([]byte, error) {
+	return c.doRequest(ctx, http.MethodPost, endpoint, payload)
+}
+
+// PutContext is Put with a context; see GetContext.
+func (c *APIClient) PutContext(ctx context.Context, endpoint string, payload interface{}) ([]byte, error) {
+	return c.doRequest(ctx, http.MethodPut, endpoint, payload)
+}
+
+// PatchContext is Patch with a context; see GetContext.
+func (c *APIClient) PatchContext(ctx context.Context, endpoint string, payload interface{}) ([]byte, error) {
+	return c.doRequest(ctx, http.MethodPatch, endpoint, payload)
+}
+
+// DeleteContext is Delete with a context; see GetContext.
+func (c *APIClient) DeleteContext(ctx context.Context, endpoint string) ([]byte, error) {
+	return c.doRequest(ctx, http.MethodDelete, endpoint, nil)
+}
+
+// doRequest sends a single request and returns the response body. Non-2xx
+// responses are returned as *APIError.
+func (c *APIClient) doRequest(ctx context.Context, method, endpoint string, payload interface{}) ([]byte, error) {
+	resp, respBody, err := c.send(ctx, method, endpoint, payload)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(resp.StatusCode, respBody)
+	}
+	return respBody, nil
+}
+
+// send performs an authorized request and reads the whole response body,
+// whatever the status code. The returned response's Body is already closed.
+//
+// A 401 for a session the server ended early is answered by logging on
+// once more and repeating the request; see relogon.
+func (c *APIClient) send(ctx context.Context, method, endpoint string, payload interface{}) (*http.Response, []byte, error) {
+	if c.dryRun && !changesNothing(method, endpoint) {
+		return c.simulate(method, endpoint, payload)
+	}
+	c.sessionMu.Lock()
+	authorization, session := c.authorization(), c.session
+	c.sessionMu.Unlock()
+	resp, body, err := c.sendAs(ctx, method, endpoint, payload, authorization)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !c.canRelogon(endpoint) {
+		return resp, body, err
+	}
+	token, err := c.relogon(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.sendAs(ctx, method, endpoint, payload, token)
+}
+
+// canRelogon reports whether a 401 for endpoint may be retried with a new
+// session: always for a token loaded from the cache, which the server may
+// have expired before its TTL, and with refresh_session for any session
+// from Logon. Logging on again only to log off is pointless.
+func (c *APIClient) canRelogon(endpoint string) bool {
+	if strings.HasSuffix(endpoint, "/Auth/Logoff") {
+		return false
+	}
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.cachedToken || (c.config.RefreshSession && (c.token != "" || c.cookieSession))
+}
+
+// authorization returns the Authorization header value for the current
+// session: its token, nothing for a cookie session without one, or the
+// API secret when there is no session. The caller holds sessionMu.
+func (c *APIClient) authorization() string {
+	if c.token == "" && !c.cookieSession {
+		return c.config.APISecret
+	}
+	return c.token
+}
+
+// relogon logs on again after a request sent during session was refused,
+// and returns the new Authorization value. Concurrent workers whose
+// requests were refused together share one Logon: whoever comes second
+// finds the session already replaced and uses the new one.
+func (c *APIClient) relogon(session uint64) (string, error) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if c.session != session {
+		return c.authorization(), nil
+	}
+	c.cachedToken = false
+	if _, err := c.Logon(); err != nil {
+		return "", fmt.Errorf("session expired and logging on again failed: %w", err)
+	}
+	c.session++
+	return c.authorization(), nil
+}
+
+// sendAs is send with an explicit Authorization header value; an empty
+// value sends no header. Failed attempts are retried as decided by
+// shouldRetry.
+func (c *APIClient) sendAs(ctx context.Context, method, endpoint string, payload interface{}, authorization string) (*http.Response, []byte, error) {
+	var data []byte
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode request body: %w", err)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, respBody, err := c.sendOnce(ctx, method, endpoint, data, authorization)
+		if !c.shouldRetry(method, resp, err, attempt) {
+			c.audit.record(c, method, endpoint, resp, err)
+			return resp, respBody, err
+		}
+		if err := sleepContext(ctx, c.retryDelay(resp, attempt)); err != nil {
+			// The attempt that was made is still audited, with why no
+			// other followed.
+			c.audit.record(c, method, endpoint, resp, err)
+			return nil, nil, err
+		}
+	}
+}
+
+// sleepContext waits for d, or returns an error if ctx ends first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("request aborted: %w", context.Cause(ctx))
+	}
+}
+
+// shouldRetry decides whether another attempt should be made. By default,
+// idempotent requests, and POSTs when RetryPOST is set, are retried after
+// transport failures and 429, 502, 503 and 504 responses, up to
+// MaxRetries times.
+func (c *APIClient) shouldRetry(method string, resp *http.Response, err error, attempt int) bool {
+	if c.ShouldRetry != nil {
+		return c.ShouldRetry(resp, err, attempt)
+	}
+	if attempt > c.MaxRetries {
+		return false
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+	case http.MethodPost:
+		if !c.RetryPOST {
+			return false
+		}
+	default:
+		return false
+	}
+	if err != nil {
+		return errors.Is(err, errTransport)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns how long to wait after the given attempt. A 429 or
+// 503 with a Retry-After header waits as long as the server asks;
+// otherwise the delay doubles with each attempt, with jitter so that
+// parallel runs do not retry in lockstep.
+func (c *APIClient) retryDelay(resp *http.Response, attempt int) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return min(d, maxRetryDelay)
+		}
+	}
+	delay := c.RetryBaseDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxRetryDelay)
+	if delay <= 0 {
+		return 0
+	}
+	// Wait between half and all of the delay.
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// sendOnce makes a single attempt at a request.
+func (c *APIClient) sendOnce(parent context.Context, method, endpoint string, data []byte, authorization string) (*http.Response, []byte, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+
+	ctx, cancel := context.WithTimeout(parent, c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, joinURL(c.config.BaseURL, endpoint), body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	req.Header.Set("Accept", "application/json")
+	// Asking for gzip explicitly, rather than leaving it to
+	// http.Transport, means responses are decoded by roundTrip whatever
+	// transport the client was built with.
+	req.Header.Set("Accept-Encoding", "gzip")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range c.Headers {
+		req.Header[name] = values
+	}
+
+	// Waiting for the limiter is not part of the request's timeout.
+	if err := c.limiter.wait(parent); err != nil {
+		return nil, nil, err
+	}
+	start := time.Now()
+	resp, respBody, err := c.roundTrip(parent, req)
+	elapsed := time.Since(start)
+	c.requestLog.record(req, data, resp, respBody, err, elapsed)
+	c.timings.record(req, elapsed)
+	return resp, respBody, err
+}
+
+// roundTrip sends req and reads the whole response body. parent is the
+// context of the request without its per-attempt timeout.
+func (c *APIClient) roundTrip(parent context.Context, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// The caller gave up, which no retry can fix; only the per-attempt
+		// timeout counts as a transport failure.
+		if parent.Err() != nil {
+			return nil, nil, fmt.Errorf("request aborted: %w", context.Cause(parent))
+		}
+		// An untrusted certificate will not fix itself on retry.
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) {
+			return nil, nil, fmt.Errorf("TLS certificate verification failed: %w", err)
+		}
+		return nil, nil, fmt.Errorf("%w: %w", errTransport, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := decodedBody(resp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", errTransport, err)
+	}
+	defer body.Close()
+
+	// A partial body would otherwise surface later as a baffling JSON
+	// syntax error, so report it as the network failure it is.
+	respBody, err := io.ReadAll(body)
+	if err != nil {
+		if parent.Err() != nil {
+			return nil, nil, fmt.Errorf("request aborted: %w", context.Cause(parent))
+		}
+		return nil, nil, fmt.Errorf("%w: %w after %d bytes: %w", errTransport, errTruncated, len(respBody), err)
+	}
+	return resp, respBody, nil
+}
+
+// decodedBody returns resp's body, decompressed when the server sent it
+// gzip-encoded. The response's headers are then changed to describe the
+// decoded body, as http.Transport does when it decompresses itself.
+func decodedBody(resp *http.Response) (io.ReadCloser, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, nil
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	gz, err := gzip.NewReader(resp.Body)
+	if errors.Is(err, io.EOF) {
+		// Bodiless responses such as a 204 may still carry the header.
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip response: %w", err)
+	}
+	return gz, nil
+}
+
+// headerName matches the token characters allowed in a header name.
+var headerName = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// parseHeaders parses "Name: Value" options into headers for
+// APIClient.Headers. Authorization is rejected unless allowAuth is set,
+// since replacing it silently breaks every authenticated request.
+func parseHeaders(options []string, allowAuth bool) (http.Header, error) {
+	headers := http.Header{}
+	for _, opt := range options {
+		name, value, ok := strings.Cut(opt, ":")
+		name = strings.TrimSpace(name)
+		if !ok || !headerName.MatchString(name) {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: Value\"", opt)
+		}
+		value = strings.TrimSpace(value)
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid header %q: value contains a line break", opt)
+		}
+		if strings.EqualFold(name, "Authorization") && !allowAuth {
+			return nil, errors.New("--header cannot set Authorization unless --allow-override-auth is given")
+		}
+		headers.Add(name, value)
+	}
+	return headers, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"golang.org/x/term"
+)
+
+// terminalWidth returns the width of the terminal on stdout, falling back
+// to $COLUMNS and then 80 when stdout is not a terminal.
+func terminalWidth() int {
+	if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 0 {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		return w
+	}
+	return 80
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 1 {
+		return string(r[:n])
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// compactRenderer renders accounts with printAccountsCompact for the
+// --compact flag of the account list workflows. Like the table, it needs
+// every row to size its columns, so it prints them all at finish.
+type compactRenderer struct {
+	w        io.Writer
+	accounts []Account
+}
+
+func (r *compactRenderer) write(accounts []Account) error {
+	r.accounts = append(r.accounts, accounts...)
+	return nil
+}
+
+func (r *compactRenderer) finish() error {
+	printAccountsCompact(r.w, r.accounts, terminalWidth())
+	return nil
+}
+
+// newAccountRenderer returns a compactRenderer when compact is set, and
+// the renderer for o otherwise.
+func newAccountRenderer(o outputOptions, compact bool, w io.Writer, columns []column[Account]) (renderer[Account], error) {
+	if compact {
+		return &compactRenderer{w: w}, nil
+	}
+	return newRenderer(o, w, columns)
+}
+
+// printAccountsCompact writes one line per account in the form
+// "id  safe/username@address  platform  status", truncating fields so no
+// line is wider than width.
+func printAccountsCompact(w io.Writer, accounts []Account, width int) {
+	const (
+		idWidth       = 12
+		platformWidth = 20
+		statusWidth   = 7
+		gaps          = 3 * 2
+	)
+	idCol, platformCol := 0, 0
+	for _, a := range accounts {
+		idCol = max(idCol, len([]rune(a.ID)))
+		platformCol = max(platformCol, len([]rune(a.PlatformID)))
+	}
+	idCol, platformCol = min(idCol, idWidth), min(platformCol, platformWidth)
+	targetCol := max(width-idCol-platformCol-statusWidth-gaps, 10)
+
+	for _, a := range accounts {
+		target := fmt.Sprintf("%s/%s@%s", a.SafeName, a.UserName, a.Address)
+		fmt.Fprintf(w, "%-*s  %-*s  %-*s  %s\n",
+			idCol, truncate(a.ID, idCol),
+			targetCol, truncate(target, targetCol),
+			platformCol, truncate(a.PlatformID, platformCol),
+			truncate(valueOr(a.SecretManagement.Status, "-"), statusWidth))
+	}
+}
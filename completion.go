@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// FlagSpec describes one flag of a workflow, for help and shell completion.
+type FlagSpec struct {
+	Name       string
+	Usage      string
+	TakesValue bool
+
+	// ValueName names the flag's value in help, such as "string" or a
+	// name quoted in Usage with backquotes; Default is its default, or
+	// "" when that is the zero value.
+	ValueName string
+	Default   string
+}
+
+// flagSetProbe, when set, is given every flag set newFlagSet creates.
+var flagSetProbe func(*flag.FlagSet)
+
+// flagSpecs returns the flags defined on fs, sorted by name.
+func flagSpecs(fs *flag.FlagSet) []FlagSpec {
+	var specs []FlagSpec
+	fs.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		name, usage := flag.UnquoteUsage(f)
+		spec := FlagSpec{Name: f.Name, Usage: usage, TakesValue: !ok || !b.IsBoolFlag(), ValueName: name}
+		switch f.DefValue {
+		case "", "0", "false", "0s", "[]":
+		default:
+			spec.Default = f.DefValue
+		}
+		specs = append(specs, spec)
+	})
+	return specs
+}
+
+// workflowFlags returns the flags wf accepts. Workflows define their
+// flags in Execute, so unless wf is a DescribableWorkflow it is run with
+// -help against an empty client and the flag set it creates is read;
+// Execute returns at the parse, before using the client. A workflow that
+// does not create its flag set that way gets no flag completion.
+func workflowFlags(wf Workflow) (specs []FlagSpec) {
+	if d, ok := wf.(DescribableWorkflow); ok {
+		return d.FlagSpecs()
+	}
+	var fs *flag.FlagSet
+	flagSetProbe = func(f *flag.FlagSet) {
+		f.SetOutput(io.Discard)
+		f.Usage = func() {}
+		if fs == nil {
+			fs = f
+		}
+	}
+	defer func() {
+		flagSetProbe = nil
+		if recover() != nil {
+			specs = nil
+		}
+	}()
+	if err := wf.Execute(&APIClient{config: &Config{}}, []string{"-help"}); !errors.Is(err, flag.ErrHelp) || fs == nil {
+		return nil
+	}
+	return flagSpecs(fs)
+}
+
+// flagWord is how a flag is offered for completion: -x for single-letter
+// flags, --name for the rest.
+func flagWord(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// completionScripts generates the completion script for each shell.
+var completionScripts = map[string]func(io.Writer, []FlagSpec, map[string][]FlagSpec) error{
+	"bash": writeBashCompletion,
+	"zsh":  writeZshCompletion,
+	"fish": writeFishCompletion,
+}
+
+// CompletionWorkflow prints a shell completion script for the workflow
+// names and their flags.
+type CompletionWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("completion", &CompletionWorkflow{})
+}
+
+// configless implements configless: completion is set up once per shell,
+// before any vault is configured.
+func (w *CompletionWorkflow) configless() {}
+
+// Synopsis implements DescribableWorkflow.
+func (w *CompletionWorkflow) Synopsis() string {
+	return "print a bash, zsh or fish completion script"
+}
+
+// FlagSpecs implements DescribableWorkflow. The shell is an argument,
+// not a flag.
+func (w *CompletionWorkflow) FlagSpecs() []FlagSpec { return nil }
+
+// Execute implements Workflow.
+func (w *CompletionWorkflow) Execute(client *APIClient, args []string) error {
+	fs := newFlagSet("completion", "bash|zsh|fish")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("name the shell: bash, zsh or fish")
+	}
+	write, ok := completionScripts[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q: must be bash, zsh or fish", fs.Arg(0))
+	}
+
+	global, _ := newGlobalFlagSet()
+	workflows := map[string][]FlagSpec{}
+	for _, name := range workflowNames() {
+		workflows[name] = workflowFlags(WorkflowRegistry[name])
+	}
+	return write(os.Stdout, flagSpecs(global), workflows)
+}
+
+// bashFlagWords returns the completion words of flags, and those of the
+// flags that take a value.
+func bashFlagWords(flags []FlagSpec) (words, values string) {
+	var all, valued []string
+	for _, f := range flags {
+		all = append(all, flagWord(f.Name))
+		if f.TakesValue {
+			valued = append(valued, flagWord(f.Name))
+		}
+	}
+	return strings.Join(all, " "), strings.Join(valued, " ")
+}
+
+func writeBashCompletion(w io.Writer, global []FlagSpec, workflows map[string][]FlagSpec) error {
+	_, err := io.WriteString(w, "# bash completion for cyberark; load it with: source <(cyberark completion bash)\n"+bashCompletion(global, workflows))
+	return err
+}
+
+// bashCompletion returns the bash completion function and its registration.
+func bashCompletion(global []FlagSpec, workflows map[string][]FlagSpec) string {
+	globalWords, globalValues := bashFlagWords(global)
+	names := make([]string, 0, len(workflows))
+	for name := range workflows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("_cyberark() {\n")
+	b.WriteString("    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} workflow=\"\" words values i\n")
+	b.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("        case ${COMP_WORDS[i]} in\n")
+	if globalValues != "" {
+		fmt.Fprintf(&b, "            %s) ((i++)) ;;\n", strings.ReplaceAll(globalValues, " ", "|"))
+	}
+	b.WriteString("            -*) ;;\n")
+	b.WriteString("            *) workflow=${COMP_WORDS[i]}; break ;;\n")
+	b.WriteString("        esac\n")
+	b.WriteString("    done\n")
+	b.WriteString("    case $workflow in\n")
+	fmt.Fprintf(&b, "        \"\") words=%q; values=%q ;;\n", strings.TrimSpace(globalWords+" "+strings.Join(names, " ")), globalValues)
+	for _, name := range names {
+		words, values := bashFlagWords(workflows[name])
+		fmt.Fprintf(&b, "        %s) words=%q; values=%q ;;\n", name, words, values)
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("    # After a flag that takes a value, fall back to file names.\n")
+	b.WriteString("    if [[ -n $prev && \" $values \" == *\" $prev \"* ]]; then\n")
+	b.WriteString("        COMPREPLY=()\n")
+	b.WriteString("        return\n")
+	b.WriteString("    fi\n")
+	b.WriteString("    COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -o default -F _cyberark cyberark\n")
+	return b.String()
+}
+
+// writeZshCompletion emits the bash script behind zsh's bash completion
+// emulation, which supports everything it uses.
+func writeZshCompletion(w io.Writer, global []FlagSpec, workflows map[string][]FlagSpec) error {
+	_, err := io.WriteString(w, "#compdef cyberark\n# zsh completion for cyberark; load it with: source <(cyberark completion zsh)\n"+
+		"autoload -U +X bashcompinit && bashcompinit\n"+bashCompletion(global, workflows))
+	return err
+}
+
+// fishQuote quotes s as a single-quoted fish string.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// fishFlag returns the complete options that describe f.
+func fishFlag(f FlagSpec) string {
+	opt := "-l " + f.Name
+	if len(f.Name) == 1 {
+		opt = "-s " + f.Name
+	}
+	if f.TakesValue {
+		opt += " -r"
+	}
+	return opt + " -d " + fishQuote(f.Usage)
+}
+
+func writeFishCompletion(w io.Writer, global []FlagSpec, workflows map[string][]FlagSpec) error {
+	names := make([]string, 0, len(workflows))
+	for name := range workflows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("# fish completion for cyberark; load it with: cyberark completion fish | source\n")
+	fmt.Fprintf(&b, "complete -c cyberark -n __fish_use_subcommand -f -a %s\n", fishQuote(strings.Join(names, " ")))
+	for _, f := range global {
+		fmt.Fprintf(&b, "complete -c cyberark -n __fish_use_subcommand %s\n", fishFlag(f))
+	}
+	for _, name := range names {
+		for _, f := range workflows[name] {
+			fmt.Fprintf(&b, "complete -c cyberark -n %s %s\n", fishQuote("__fish_seen_subcommand_from "+name), fishFlag(f))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+)
+
+// panicWorkflow uses its client before parsing its flags.
+type panicWorkflow struct{}
+
+func (panicWorkflow) Execute(client *APIClient, args []string) error {
+	_ = client.config.CCP.URL
+	return nil
+}
+
+func TestWorkflowFlagsReadsTheFlagSet(t *testing.T) {
+	flags := map[string]FlagSpec{}
+	for _, f := range workflowFlags(WorkflowRegistry["list-accounts"]) {
+		flags[f.Name] = f
+	}
+	if f, ok := flags["safe"]; !ok || !f.TakesValue {
+		t.Errorf("list-accounts --safe = %+v, want a flag taking a value", f)
+	}
+	if f, ok := flags["all"]; !ok || f.TakesValue {
+		t.Errorf("list-accounts --all = %+v, want a boolean flag", f)
+	}
+	if flagSetProbe != nil {
+		t.Error("flagSetProbe left set after workflowFlags")
+	}
+
+	if got := workflowFlags(panicWorkflow{}); got != nil {
+		t.Errorf("workflowFlags(panicking workflow) = %v, want nil", got)
+	}
+	if flagSetProbe != nil {
+		t.Error("flagSetProbe left set after a panic")
+	}
+}
+
+// describedWorkflow lists its flags rather than having them probed.
+type describedWorkflow struct{ panicWorkflow }
+
+func (describedWorkflow) Synopsis() string { return "a described workflow" }
+
+func (describedWorkflow) FlagSpecs() []FlagSpec {
+	return []FlagSpec{{Name: "id", Usage: "account ID", TakesValue: true}}
+}
+
+func TestDescribableWorkflowsAreNotRun(t *testing.T) {
+	if got := workflowFlags(describedWorkflow{}); len(got) != 1 || got[0].Name != "id" {
+		t.Errorf("workflowFlags(described workflow) = %v, want its FlagSpecs", got)
+	}
+	if err := printWorkflowUsage("described", describedWorkflow{}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("printWorkflowUsage(described workflow) = %v, want flag.ErrHelp", err)
+	}
+	for args, want := range map[string]bool{"--help": true, "--safe x -h": true, "--safe x": false, "-- --help": false} {
+		if got := helpRequested(strings.Fields(args)); got != want {
+			t.Errorf("helpRequested(%q) = %v, want %v", args, got, want)
+		}
+	}
+}
+
+func TestBashCompletionSkipsGlobalFlagValues(t *testing.T) {
+	var b strings.Builder
+	global := []FlagSpec{{Name: "config", TakesValue: true}, {Name: "v"}}
+	workflows := map[string][]FlagSpec{"list-accounts": {{Name: "safe", TakesValue: true}, {Name: "all"}}}
+	if err := writeBashCompletion(&b, global, workflows); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"--config) ((i++)) ;;",
+		`"") words="--config -v list-accounts"; values="--config" ;;`,
+		`list-accounts) words="--safe --all"; values="--safe" ;;`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("bash completion is missing %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestEveryWorkflowDescribesItself(t *testing.T) {
+	for name, wf := range WorkflowRegistry {
+		d, ok := wf.(DescribableWorkflow)
+		if !ok {
+			t.Errorf("%s does not implement DescribableWorkflow", name)
+			continue
+		}
+		if d.Synopsis() == "" {
+			t.Errorf("%s has no synopsis", name)
+		}
+		// FlagSpecs must list the flags Execute parses.
+		probed := workflowFlags(struct{ Workflow }{wf})
+		var described, found []string
+		for _, s := range d.FlagSpecs() {
+			described = append(described, s.Name)
+		}
+		for _, s := range probed {
+			found = append(found, s.Name)
+		}
+		if strings.Join(described, ",") != strings.Join(found, ",") {
+			t.Errorf("%s FlagSpecs = %v, Execute parses %v", name, described, found)
+		}
+	}
+}
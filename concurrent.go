@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// runConcurrent calls fn for every item using at most concurrency
+// goroutines at a time. The returned slice holds each item's error at the
+// item's index; a failure does not stop the remaining items. Once ctx is
+// done no more items are started, and those left get ctx's cause as their
+// error; items already running see the cancellation through their own
+// requests.
+func runConcurrent[T any](ctx context.Context, items []T, concurrency int, fn func(T) error) []error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	errs := make([]error, len(items))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			for j := i; j < len(items); j++ {
+				errs[j] = context.Cause(ctx)
+			}
+			break
+		}
+		wg.Add(1)
+		go func(i int, item T) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(item)
+		}(i, item)
+	}
+	wg.Wait()
+	return errs
+}
+
+// progress reports "label done/total" on a single stderr line while a bulk
+// operation runs. It prints nothing when stderr is not a terminal, so logs
+// are not filled with carriage returns.
+type progress struct {
+	mu      sync.Mutex
+	label   string
+	total   int
+	done    int
+	enabled bool
+}
+
+func newProgress(label string, total int) *progress {
+	return &progress{label: label, total: total, enabled: isTerminal(os.Stderr)}
+}
+
+// step records one finished item.
+func (p *progress) step() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if p.enabled {
+		fmt.Fprintf(os.Stderr, "\r%s %d/%d", p.label, p.done, p.total)
+	}
+}
+
+// warn prints a message on its own line without garbling the progress line.
+func (p *progress) warn(format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.enabled {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+	if p.enabled {
+		fmt.Fprintf(os.Stderr, "\r%s %d/%d", p.label, p.done, p.total)
+	}
+}
+
+// finish ends the progress line.
+func (p *progress) finish() {
+	if p.enabled && p.done > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunConcurrentBoundsWorkersAndCollectsErrors(t *testing.T) {
+	items := make([]int, 20)
+	for i := range items {
+		items[i] = i
+	}
+	var running, peak atomic.Int32
+	errs := runConcurrent(context.Background(), items, 3, func(i int) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		if i%5 == 0 {
+			return errors.New("odd one out")
+		}
+		return nil
+	})
+	if peak.Load() > 3 {
+		t.Errorf("%d items ran at once, want at most 3", peak.Load())
+	}
+	for i, err := range errs {
+		if (err != nil) != (i%5 == 0) {
+			t.Errorf("item %d: error = %v", i, err)
+		}
+	}
+}
+
+func TestRunConcurrentStopsDispatchingWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var started atomic.Int32
+	errs := runConcurrent(ctx, make([]int, 10), 1, func(int) error {
+		if started.Add(1) == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if n := started.Load(); n != 2 {
+		t.Errorf("%d items started, want 2", n)
+	}
+	for i, err := range errs[2:] {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("item %d: error = %v, want context.Canceled", i+2, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes data to a 0600 config file and returns its path.
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestValidateBaseURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseURL     string
+		assumeHTTPS bool
+		want        string
+		wantErr     string
+	}{
+		{name: "https", baseURL: "https://pvwa.corp.com", want: "https://pvwa.corp.com"},
+		{name: "http", baseURL: "http://pvwa.corp.com:8080", want: "http://pvwa.corp.com:8080"},
+		{name: "no scheme", baseURL: "pvwa.corp.com", wantErr: "must include a scheme"},
+		{name: "no scheme with port", baseURL: "pvwa.corp.com:443", wantErr: "must include a scheme"},
+		{name: "assume https", baseURL: "pvwa.corp.com", assumeHTTPS: true, want: "https://pvwa.corp.com"},
+		{name: "other scheme", baseURL: "ftp://pvwa.corp.com", wantErr: "must use https:// or http://"},
+		{name: "no host", baseURL: "https://", wantErr: "has no host"},
+		{name: "no host with path", baseURL: "https:///PasswordVault", wantErr: "has no host"},
+		{name: "unparsable", baseURL: "https://pvwa corp.com", wantErr: "not a valid URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{BaseURL: tt.baseURL, APISecret: "secret", AssumeHTTPS: tt.assumeHTTPS}
+			err := c.validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validate() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+			if c.BaseURL != tt.want {
+				t.Errorf("BaseURL = %q, want %q", c.BaseURL, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRejectsMissingScheme(t *testing.T) {
+	path := writeConfig(t, `{"base_url": "pvwa.corp.com", "api_secret": "secret"}`)
+	_, err := loadConfig(path, defaultProfile)
+	if err == nil || !strings.Contains(err.Error(), "https://pvwa.corp.com") {
+		t.Fatalf("loadConfig() error = %v, want a hint with the https:// form", err)
+	}
+}
+
+func TestValidateAuthMethod(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		wantMethod string
+		wantErr    string
+	}{
+		{name: "default", config: Config{APISecret: "secret"}, wantMethod: "cyberark"},
+		{name: "case-insensitive", config: Config{AuthMethod: "LDAP", Username: "svc", APISecret: "secret"}, wantMethod: "ldap"},
+		{name: "unknown", config: Config{AuthMethod: "saml", APISecret: "secret"}, wantErr: `unknown auth_method "saml"`},
+		{name: "radius without username", config: Config{AuthMethod: "radius", APISecret: "secret"}, wantErr: "requires username and api_secret"},
+		{name: "windows without credentials", config: Config{AuthMethod: "windows"}, wantMethod: "windows"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.config
+			c.BaseURL = "https://pvwa.corp.com"
+			err := c.validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validate() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+			if c.AuthMethod != tt.wantMethod {
+				t.Errorf("AuthMethod = %q, want %q", c.AuthMethod, tt.wantMethod)
+			}
+		})
+	}
+}
+
+func TestLoadConfigResolvesSecretReferences(t *testing.T) {
+	t.Setenv("CYBERARK_TEST_SECRET", "from-env")
+	tests := []struct {
+		secret  string
+		want    string
+		wantErr string
+	}{
+		{secret: "literal", want: "literal"},
+		{secret: "env:CYBERARK_TEST_SECRET", want: "from-env"},
+		{secret: "env:CYBERARK_TEST_UNSET", wantErr: "CYBERARK_TEST_UNSET is not set"},
+		{secret: "exec:echo  from-exec ", want: "from-exec"},
+		{secret: "exec:false", wantErr: "command false failed"},
+		{secret: "exec:true", wantErr: "printed nothing"},
+	}
+	for _, tt := range tests {
+		path := writeConfig(t, `{"base_url": "https://pvwa.corp.com", "api_secret": "`+tt.secret+`"}`)
+		config, err := loadConfig(path, defaultProfile)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("api_secret %q: error = %v, want %q", tt.secret, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("api_secret %q: %v", tt.secret, err)
+		} else if config.APISecret != tt.want {
+			t.Errorf("api_secret %q resolved to %q, want %q", tt.secret, config.APISecret, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigSelectsProfile(t *testing.T) {
+	path := writeConfig(t, `{"profiles": {
+		"default": {"base_url": "https://dev.corp.com", "api_secret": "dev"},
+		"prod": {"base_url": "https://prod.corp.com", "api_secret": "prod"}
+	}}`)
+	config, err := loadConfig(path, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BaseURL != "https://prod.corp.com" || config.APISecret != "prod" {
+		t.Errorf("loaded %s with secret %q, want the prod profile", config.BaseURL, config.APISecret)
+	}
+	if config, err = loadConfig(path, defaultProfile); err != nil || config.BaseURL != "https://dev.corp.com" {
+		t.Errorf("default profile: got %v, %v", config, err)
+	}
+	_, err = loadConfig(path, "staging")
+	if err == nil || !strings.Contains(err.Error(), "available profiles: default, prod") {
+		t.Errorf("missing profile: error = %v, want the available names", err)
+	}
+
+	flat := writeConfig(t, `{"base_url": "https://pvwa.corp.com", "api_secret": "secret"}`)
+	if _, err := loadConfig(flat, defaultProfile); err != nil {
+		t.Errorf("flat config: %v", err)
+	}
+	if _, err := loadConfig(flat, "prod"); err == nil {
+		t.Error("flat config accepted --profile prod")
+	}
+}
+
+func TestLoadConfigAppliesEnvOverrides(t *testing.T) {
+	path := writeConfig(t, `{"base_url": "https://file.corp.com", "api_secret": "file", "timeout": 10}`)
+	t.Setenv("CYBERARK_BASE_URL", "https://env.corp.com")
+	t.Setenv("CYBERARK_TIMEOUT", "45")
+	t.Setenv("CYBERARK_SESSION_COOKIE", "true")
+	config, err := loadConfig(path, defaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BaseURL != "https://env.corp.com" || config.Timeout != 45 || !config.SessionCookie || config.APISecret != "file" {
+		t.Errorf("got %+v, want the environment to override base_url, timeout and session_cookie only", config)
+	}
+
+	t.Setenv("CYBERARK_API_SECRET", "env")
+	config, err = loadConfig(filepath.Join(t.TempDir(), "missing.json"), defaultProfile)
+	if err != nil {
+		t.Fatalf("no config file: %v", err)
+	}
+	if config.APISecret != "env" {
+		t.Errorf("no config file: api_secret = %q, want env", config.APISecret)
+	}
+
+	t.Setenv("CYBERARK_TIMEOUT", "soon")
+	if _, err := loadConfig(path, defaultProfile); err == nil || !strings.Contains(err.Error(), "CYBERARK_TIMEOUT") {
+		t.Errorf("bad CYBERARK_TIMEOUT: error = %v", err)
+	}
+}
+
+func TestValidateBaseURLNormalizes(t *testing.T) {
+	tests := []struct {
+		base, want, wantErr string
+	}{
+		{base: "https://pvwa.corp.com", want: "https://pvwa.corp.com"},
+		{base: "https://pvwa.corp.com/", want: "https://pvwa.corp.com"},
+		{base: "https://pvwa.corp.com/PasswordVault", want: "https://pvwa.corp.com"},
+		{base: "https://pvwa.corp.com/PasswordVault/", want: "https://pvwa.corp.com"},
+		{base: "https://pvwa.corp.com/passwordvault/API/", want: "https://pvwa.corp.com"},
+		{base: "https://gw.corp.com/cyberark/PasswordVault", want: "https://gw.corp.com/cyberark"},
+		{base: "https://acme.privilegecloud.cyberark.cloud/PasswordVault/", want: "https://acme.privilegecloud.cyberark.cloud"},
+		{base: "https://acme.privilegecloud.cyberark.cloud/pvwa", wantErr: "at the root of the host"},
+		{base: "https://acme.cyberark.cloud", wantErr: "https://acme.privilegecloud.cyberark.cloud"},
+		{base: "https://pvwa.corp.com/?x=1", wantErr: "query or fragment"},
+	}
+	for _, tt := range tests {
+		c := Config{BaseURL: tt.base}
+		err := c.validateBaseURL()
+		switch {
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("validateBaseURL(%q) = %v, want an error containing %q", tt.base, err, tt.wantErr)
+		case tt.wantErr == "" && (err != nil || c.BaseURL != tt.want):
+			t.Errorf("validateBaseURL(%q) = %q, %v; want %q", tt.base, c.BaseURL, err, tt.want)
+		}
+	}
+}
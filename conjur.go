@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ConjurConfig holds the settings for the Conjur secrets backend. It is
+// kept separate from the PVWA settings in Config since the two products
+// use different hosts and credentials.
+type ConjurConfig struct {
+	URL     string `json:"url"`
+	Account string `json:"account"`
+	Login   string `json:"login"`
+	APIKey  string `json:"api_key"`
+}
+
+func (c *ConjurConfig) validate() error {
+	switch {
+	case c.URL == "":
+		return errors.New("config: conjur.url is required")
+	case c.Account == "":
+		return errors.New("config: conjur.account is required")
+	case c.Login == "":
+		return errors.New("config: conjur.login is required")
+	case c.APIKey == "":
+		return errors.New("config: conjur.api_key is required")
+	}
+	return nil
+}
+
+// ConjurClient retrieves secrets from Conjur using host identity and API
+// key authentication.
+type ConjurClient struct {
+	config     *ConjurConfig
+	httpClient *http.Client
+
+	// ctx, requestLog and timings are the PVWA client's, so interrupts,
+	// --deadline, --verbose and --timing cover Conjur requests too.
+	ctx        context.Context
+	requestLog *requestLogger
+	timings    *requestTimings
+}
+
+// NewConjurClient returns a Conjur client that shares the PVWA client's
+// transport settings, timeout, context and request tracing.
+func NewConjurClient(config *ConjurConfig, client *APIClient) *ConjurClient {
+	return &ConjurClient{
+		config:     config,
+		httpClient: &http.Client{Transport: client.httpClient.Transport, Timeout: client.timeout},
+		ctx:        client.baseContext(),
+		requestLog: client.requestLog,
+		timings:    client.timings,
+	}
+}
+
+// authenticate exchanges the API key for a short-lived access token and
+// returns it in the base64 form expected by the Authorization header.
+func (c *ConjurClient) authenticate() (string, error) {
+	endpoint := fmt.Sprintf("authn/%s/%s/authenticate",
+		url.PathEscape(c.config.Account), url.PathEscape(c.config.Login))
+	token, err := c.do(http.MethodPost, endpoint, c.config.APIKey, "")
+	if err != nil {
+		return "", fmt.Errorf("conjur authentication failed: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(token), nil
+}
+
+// GetSecret returns the value of the variable with the given id.
+func (c *ConjurClient) GetSecret(id string) ([]byte, error) {
+	token, err := c.authenticate()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("secrets/%s/variable/%s",
+		url.PathEscape(c.config.Account), url.PathEscape(id))
+	return c.do(http.MethodGet, endpoint, "", token)
+}
+
+func (c *ConjurClient) do(method, endpoint, body, token string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(c.ctx, method, fmt.Sprintf("%s/%s", strings.TrimRight(c.config.URL, "/"), endpoint), strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Token token=%q", token))
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	var respBody []byte
+	if err == nil {
+		respBody, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			err = fmt.Errorf("failed to read response: %w", err)
+		}
+	} else {
+		err = fmt.Errorf("%w: %w", errTransport, err)
+	}
+	elapsed := time.Since(start)
+	// Every Conjur body is a secret: the API key going in, and the access
+	// token or variable value coming back.
+	c.requestLog.record(req, conjurTraceBody([]byte(body)), resp, conjurTraceBody(respBody), err, elapsed)
+	c.timings.record(req, elapsed)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("conjur error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// conjurTraceBody stands in for a Conjur request or response body in
+// --debug traces.
+func conjurTraceBody(body []byte) []byte {
+	if len(body) == 0 {
+		return nil
+	}
+	return []byte(`"` + redacted + `"`)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConjurRequestsAreTracedAndCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/authenticate") {
+			w.Write([]byte(`{"protected":"p","payload":"access-token","signature":"s"}`))
+			return
+		}
+		w.Write([]byte("db-password"))
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	var trace strings.Builder
+	client.requestLog = &requestLogger{w: &trace, bodies: true}
+	conjur := &ConjurConfig{URL: srv.URL, Account: "corp", Login: "host/app", APIKey: "api-key-123"}
+	secret, err := NewConjurClient(conjur, client).GetSecret("prod/db/password")
+	if err != nil || string(secret) != "db-password" {
+		t.Fatalf("GetSecret() = %q, %v", secret, err)
+	}
+	for _, leak := range []string{"api-key-123", "access-token", "db-password"} {
+		if strings.Contains(trace.String(), leak) {
+			t.Errorf("trace shows %q:\n%s", leak, trace.String())
+		}
+	}
+	if !strings.Contains(trace.String(), "/secrets/corp/variable/") {
+		t.Errorf("trace does not show the variable request:\n%s", trace.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.ctx = ctx
+	if _, err := NewConjurClient(conjur, client).GetSecret("prod/db/password"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetSecret() after an interrupt = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// bodySnippetLength is how much of an unparseable response body decode
+// errors quote.
+const bodySnippetLength = 120
+
+// GetInto sends a GET request to endpoint and decodes the JSON response
+// into a T. Build an endpoint with query parameters with withQuery.
+func GetInto[T any](c *APIClient, endpoint string) (T, error) {
+	data, err := c.Get(endpoint)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decodeResponse[T](endpoint, data)
+}
+
+// PostInto sends a POST request to endpoint and decodes the JSON response
+// into a T.
+func PostInto[T any](c *APIClient, endpoint string, payload interface{}) (T, error) {
+	data, err := c.Post(endpoint, payload)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decodeResponse[T](endpoint, data)
+}
+
+// decodeResponse decodes a response body from endpoint into a T. When
+// that fails the error quotes the start of the body, since the usual
+// cause is not a changed API but something other than the PVWA
+// answering: a proxy's login or error page, or a base_url that points at
+// the wrong web site. Secret fields in the quote are redacted; see
+// bodySnippet.
+func decodeResponse[T any](endpoint string, data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	if err == nil {
+		return v, nil
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return v, fmt.Errorf("failed to parse response from %s: the response is empty", endpoint)
+	}
+	snippet := bodySnippet(trimmed)
+	if trimmed[0] == '<' {
+		return v, fmt.Errorf("failed to parse response from %s: the server returned an HTML page instead of JSON; check base_url, and whether a proxy or login page is in front of the PVWA. The page begins: %s", endpoint, snippet)
+	}
+	return v, fmt.Errorf("failed to parse response from %s: %w; the response begins: %s", endpoint, err, snippet)
+}
+
+// bodySnippet returns the start of a body on one line, with secrets
+// redacted. Invalid JSON cannot be redacted field by field, so if it
+// mentions a sensitive key it is not quoted at all.
+func bodySnippet(data []byte) string {
+	if !json.Valid(data) {
+		lower := strings.ToLower(string(data))
+		for key := range sensitiveKeys {
+			if strings.Contains(lower, `"`+key+`"`) {
+				return fmt.Sprintf("(%d bytes, not shown because they may hold a secret)", len(data))
+			}
+		}
+	}
+	return truncate(strings.Join(strings.Fields(string(redactBody(data))), " "), bodySnippetLength)
+}
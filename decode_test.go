@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetIntoExplainsUnparseableResponses(t *testing.T) {
+	bodies := map[string]string{
+		"/html":   "<!DOCTYPE html>\n<html><head><title>Sign in</title></head></html>",
+		"/broken": `{"Password": "hunter2", "name": `,
+		"/ok":     `{"safeName": "Ops"}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(bodies[r.URL.Path]))
+	}))
+	defer srv.Close()
+	client := newTestClient(t, srv)
+
+	safe, err := GetInto[Safe](client, "ok")
+	if err != nil || safe.SafeName != "Ops" {
+		t.Fatalf("GetInto(ok) = %+v, %v; want safe Ops", safe, err)
+	}
+
+	_, err = GetInto[Safe](client, "html")
+	if err == nil || !strings.Contains(err.Error(), "HTML page instead of JSON") || !strings.Contains(err.Error(), "<title>Sign in</title>") {
+		t.Errorf("GetInto(html) error = %v, want it to name the HTML page and quote it", err)
+	}
+
+	_, err = GetInto[Safe](client, "broken")
+	if err == nil || !strings.Contains(err.Error(), "the response begins") {
+		t.Errorf("GetInto(broken) error = %v, want it to quote the body", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("GetInto(broken) error quotes a secret: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// changesNothing reports whether a request may still be sent under
+// --dry-run: reads, and logging off the session the run started.
+func changesNothing(method, endpoint string) bool {
+	return method == http.MethodGet || method == http.MethodHead || strings.HasSuffix(endpoint, "/Auth/Logoff")
+}
+
+// simulate implements --dry-run for a request that would change
+// something. It prints the request, with secrets in the body redacted, and
+// returns a successful response without contacting the server.
+func (c *APIClient) simulate(method, endpoint string, payload interface{}) (*http.Response, []byte, error) {
+	var data []byte
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode request body: %w", err)
+		}
+	}
+	msg := fmt.Sprintf("[DRY RUN] %s %s not sent\n", method, joinURL(c.config.BaseURL, endpoint))
+	if data != nil {
+		var out bytes.Buffer
+		if err := json.Indent(&out, redactJSON(data), "", "  "); err == nil {
+			msg += out.String() + "\n"
+		}
+	}
+	fmt.Fprint(os.Stderr, msg)
+
+	body, err := json.Marshal(simulatedResponse("dry-run", method, endpoint, data))
+	if err != nil {
+		return nil, nil, err
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+	return resp, body, nil
+}
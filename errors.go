@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// APIError is returned for responses with a non-2xx status code. ErrorCode
+// and Message come from the vault's {"ErrorCode", "ErrorMessage"} error
+// body and are empty when the body is something else, such as a proxy's
+// error page.
+type APIError struct {
+	StatusCode int
+	ErrorCode  string
+	Message    string
+	Body       []byte
+}
+
+// newAPIError returns the APIError for a response, parsing the vault's
+// error body if it has one.
+func newAPIError(status int, body []byte) *APIError {
+	e := &APIError{StatusCode: status, Body: body}
+	var envelope struct {
+		ErrorCode    string `json:"ErrorCode"`
+		ErrorMessage string `json:"ErrorMessage"`
+	}
+	if json.Unmarshal(body, &envelope) == nil {
+		e.ErrorCode, e.Message = envelope.ErrorCode, envelope.ErrorMessage
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	if e.ErrorCode != "" {
+		return fmt.Sprintf("API error (status %d, %s): %s", e.StatusCode, e.ErrorCode, e.Message)
+	}
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, string(e.Body))
+}
+
+// apiStatus returns the HTTP status code carried by err, or 0 if err is
+// not an API error.
+func apiStatus(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// isNotFound reports whether err is a 404 from the API.
+func isNotFound(err error) bool {
+	return apiStatus(err) == http.StatusNotFound
+}
+
+// isUnauthorized reports whether err is a 401 from the API, which means
+// the session is missing, expired or was ended.
+func isUnauthorized(err error) bool {
+	return apiStatus(err) == http.StatusUnauthorized
+}
+
+// Exit codes for --fail-if-empty and --fail-if-nonempty, distinct from the
+// status 1 used for errors so scripts can tell the cases apart.
+const (
+	exitEmpty    = 3
+	exitNonEmpty = 4
+)
+
+// exitError makes the command exit with a specific status.
+type exitError struct {
+	code int
+	msg  string
+}
+
+func (e *exitError) Error() string {
+	return e.msg
+}
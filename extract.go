@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// extractField returns the value at path in a JSON document, so a
+// workflow that needs one field of a response does not have to declare a
+// struct for it. Path elements are separated by dots, and array elements
+// are selected with [n], as in "value[0].secretManagement.status".
+//
+// Strings are returned as is, numbers exactly as they appear in the
+// document, and objects or arrays as compact JSON. null yields "".
+func extractField(body []byte, path string) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	steps, err := splitFieldPath(path)
+	if err != nil {
+		return "", err
+	}
+	for i, step := range steps {
+		at := strings.Join(steps[:i+1], ".")
+		switch node := v.(type) {
+		case map[string]interface{}:
+			value, ok := node[step]
+			if !ok {
+				return "", fmt.Errorf("field %q not found", at)
+			}
+			v = value
+		case []interface{}:
+			idx, err := strconv.Atoi(step)
+			if err != nil {
+				return "", fmt.Errorf("%q is an array and needs an index", strings.Join(steps[:i], "."))
+			}
+			if idx < 0 || idx >= len(node) {
+				return "", fmt.Errorf("index %d out of range at %q (length %d)", idx, strings.Join(steps[:i], "."), len(node))
+			}
+			v = node[idx]
+		default:
+			return "", fmt.Errorf("field %q not found: %q is not an object or array", at, strings.Join(steps[:i], "."))
+		}
+	}
+
+	switch value := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// splitFieldPath turns "a.b[0].c" into ["a", "b", "0", "c"].
+func splitFieldPath(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	invalid := fmt.Errorf("invalid field path %q", path)
+	var steps []string
+	for _, part := range strings.Split(path, ".") {
+		name, rest, hasIndex := strings.Cut(part, "[")
+		if name == "" && !hasIndex {
+			return nil, invalid
+		}
+		if name != "" {
+			steps = append(steps, name)
+		}
+		for hasIndex {
+			idx, after, ok := strings.Cut(rest, "]")
+			if !ok || idx == "" {
+				return nil, invalid
+			}
+			steps = append(steps, idx)
+			if after == "" {
+				break
+			}
+			if rest, hasIndex = strings.CutPrefix(after, "["); !hasIndex {
+				return nil, invalid
+			}
+		}
+	}
+	return steps, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+const extractSample = `{
+	"id": "12_3",
+	"count": 2,
+	"big": 12345678901234567890,
+	"enabled": true,
+	"missing": null,
+	"secretManagement": {"status": "success", "lastModifiedTime": 1700000000},
+	"value": [
+		{"id": "1_1", "tags": ["a", "b"]},
+		{"id": "1_2", "remoteMachinesAccess": {"remoteMachines": "h1;h2"}}
+	],
+	"matrix": [[1, 2], [3, 4]]
+}`
+
+func TestExtractField(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"id", "12_3"},
+		{"count", "2"},
+		{"big", "12345678901234567890"},
+		{"enabled", "true"},
+		{"missing", ""},
+		{"secretManagement.status", "success"},
+		{"secretManagement.lastModifiedTime", "1700000000"},
+		{"value[0].id", "1_1"},
+		{"value[0].tags[1]", "b"},
+		{"value.1.remoteMachinesAccess.remoteMachines", "h1;h2"},
+		{"matrix[1][0]", "3"},
+		{"value[0].tags", `["a","b"]`},
+		{"secretManagement", `{"lastModifiedTime":1700000000,"status":"success"}`},
+	}
+	for _, tt := range tests {
+		got, err := extractField([]byte(extractSample), tt.path)
+		if err != nil {
+			t.Errorf("extractField(%q): %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("extractField(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExtractFieldErrors(t *testing.T) {
+	for _, path := range []string{
+		"nope",
+		"secretManagement.nope",
+		"value[2].id",
+		"value.id",
+		"id.more",
+		"value[",
+		"value[0]x",
+		"a..b",
+	} {
+		if got, err := extractField([]byte(extractSample), path); err == nil {
+			t.Errorf("extractField(%q) = %q, want an error", path, got)
+		}
+	}
+	if _, err := extractField([]byte(`{"id":`), "id"); err == nil {
+		t.Error("expected an error for truncated JSON")
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/1kaius1/CyberArk-API-Client
+
+go 1.21
+
+require (
+	golang.org/x/sys v0.26.0
+	golang.org/x/term v0.25.0
+)
@@ -0,0 +1,271 @@
+// Command cyberark is a command-line client for the CyberArk PVWA REST API
+// that runs predefined workflows.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			fmt.Fprintln(os.Stderr, exitErr.msg)
+			os.Exit(exitErr.code)
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if isUnauthorized(err) {
+			fmt.Fprintln(os.Stderr, "The vault rejected the session; 'cyberark verify' checks that logon works.")
+		}
+		os.Exit(1)
+	}
+}
+
+// run parses the global flags, loads the config and dispatches to the
+// requested workflow.
+func run(args []string) error {
+	global, g := newGlobalFlagSet()
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+	if g.showVersion {
+		fmt.Println(versionString())
+		return nil
+	}
+
+	rest := global.Args()
+	if len(rest) == 0 {
+		printUsage()
+		return errors.New("no workflow specified")
+	}
+
+	name := rest[0]
+	wf, ok := WorkflowRegistry[name]
+	if !ok {
+		printUsage()
+		return fmt.Errorf("unknown workflow %q", name)
+	}
+	if helpRequested(rest[1:]) {
+		return printWorkflowUsage(name, wf)
+	}
+	if _, ok := wf.(configless); ok {
+		if cw, ok := wf.(configWriter); ok {
+			cw.setConfigPath(g.configPath)
+		}
+		return wf.Execute(nil, rest[1:])
+	}
+
+	var config *Config
+	var err error
+	if g.sandbox {
+		config, err = sandboxConfig()
+	} else {
+		config, err = loadConfig(g.configPath, g.profile)
+	}
+	if err != nil {
+		return err
+	}
+	if g.auditReads {
+		config.AuditReads = true
+	}
+	if isFlagSet(global, "max-results") {
+		if g.maxResults < 0 {
+			return errors.New("--max-results must not be negative")
+		}
+		config.MaxResults = g.maxResults
+	}
+	if err := validateFormat(g.outputFormat); err != nil {
+		return err
+	}
+	if g.deadline < 0 {
+		return errors.New("--deadline must not be negative")
+	}
+	headers, err := parseHeaders(g.headers, g.allowAuth)
+	if err != nil {
+		return err
+	}
+	var client *APIClient
+	if g.sandbox {
+		client, err = NewAPIClientWithHTTPClient(config, &http.Client{Transport: sandboxTransport{}})
+	} else {
+		client, err = NewAPIClient(config)
+	}
+	if err != nil {
+		return err
+	}
+	client.Headers = headers
+
+	// Ctrl-C and --deadline abort requests in flight instead of leaving
+	// the run waiting on a stuck connection.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if g.deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, g.deadline, fmt.Errorf("--deadline of %s exceeded", g.deadline))
+		defer cancel()
+	}
+	client.ctx = ctx
+	if g.verbose || g.debug {
+		client.requestLog = &requestLogger{w: os.Stderr, bodies: g.debug}
+	}
+	// The summary is deferred first so that it runs last, after Logoff.
+	if g.timing {
+		client.timings = &requestTimings{}
+		defer func() {
+			fmt.Fprintln(os.Stderr)
+			client.timings.write(os.Stderr)
+		}()
+	}
+	client.prechecks = g.precheck
+	client.dryRun = g.dryRun
+	client.outputFormat = g.outputFormat
+	if g.otp != "" {
+		client.AuthPrompt = fixedAuthResponse(g.otp)
+	}
+	if g.sandbox {
+		fmt.Fprintf(os.Stderr, "Sandbox mode: showing sample data; nothing is sent to a vault\n")
+	}
+	if g.validate {
+		if err := validateServer(client); err != nil {
+			return err
+		}
+	}
+	if config.TokenCacheTTL > 0 && !g.noCache {
+		client.cache = &tokenCache{path: config.TokenCachePath, ttl: time.Duration(config.TokenCacheTTL) * time.Second}
+	}
+	_, manages := wf.(sessionless)
+	session := config.logsOn() && !manages
+	if session {
+		if err := startSession(client); err != nil {
+			return err
+		}
+	}
+	op := client.forOperation(name)
+	// A cached session is kept for the next run. The workflow's copy of the
+	// client is logged off, since it holds the token of any new session
+	// started when the first one expired.
+	if session && client.cache == nil {
+		defer func() {
+			if err := op.Logoff(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+	}
+	return wf.Execute(op, rest[1:])
+}
+
+// globalOptions are the flags given before the workflow name.
+type globalOptions struct {
+	configPath   string
+	profile      string
+	headers      stringsFlag
+	allowAuth    bool
+	auditReads   bool
+	validate     bool
+	precheck     bool
+	otp          string
+	noCache      bool
+	sandbox      bool
+	outputFormat string
+	deadline     time.Duration
+	verbose      bool
+	debug        bool
+	maxResults   int
+	showVersion  bool
+	dryRun       bool
+	timing       bool
+}
+
+// newGlobalFlagSet returns the flag set of the global options, bound to
+// the returned globalOptions.
+func newGlobalFlagSet() (*flag.FlagSet, *globalOptions) {
+	g := &globalOptions{}
+	global := flag.NewFlagSet("cyberark", flag.ContinueOnError)
+	global.SetOutput(os.Stderr)
+	global.Usage = printUsage
+	global.StringVar(&g.configPath, "config", defaultConfigPath(), "path to the configuration file, or - to read it from stdin")
+	global.StringVar(&g.profile, "profile", defaultProfile, "profile to load from a config file that has several")
+	global.StringVar(&g.profile, "p", defaultProfile, "shorthand for --profile")
+	global.Var(&g.headers, "header", "add a \"Name: Value\" header to every request (repeatable)")
+	global.BoolVar(&g.allowAuth, "allow-override-auth", false, "allow --header to replace the Authorization header")
+	global.BoolVar(&g.auditReads, "audit-reads", false, "also record read-only requests in the audit log")
+	global.BoolVar(&g.validate, "validate-server", false, "check the configuration against the PVWA's capabilities before running the workflow")
+	global.BoolVar(&g.precheck, "precheck", false, "check the user's safe permissions before the workflow changes anything")
+	global.StringVar(&g.otp, "otp", os.Getenv("CYBERARK_OTP"), "one-time passcode for a RADIUS logon challenge, instead of a prompt (default $CYBERARK_OTP)")
+	global.BoolVar(&g.noCache, "no-cache", false, "neither use nor update the session token cache")
+	global.BoolVar(&g.sandbox, "sandbox", false, "answer requests from built-in sample data instead of a PVWA; changes are only simulated")
+	global.StringVar(&g.outputFormat, "output", "table", "default output format of list workflows: table, json, jsonl or csv")
+	global.StringVar(&g.outputFormat, "o", "table", "shorthand for --output")
+	global.DurationVar(&g.deadline, "deadline", 0, "abort the workflow if it has not finished after this long, e.g. 10m (0 for no deadline)")
+	global.BoolVar(&g.verbose, "verbose", false, "log each request's method, URL, status and duration to stderr")
+	global.BoolVar(&g.verbose, "v", false, "shorthand for --verbose")
+	global.BoolVar(&g.debug, "debug", false, "like --verbose, and also log request headers and bodies, with secrets redacted")
+	global.IntVar(&g.maxResults, "max-results", 0, "stop list and search workflows after N results, overriding max_results (0 for no limit)")
+	global.BoolVar(&g.showVersion, "version", false, "print the version and build information and exit")
+	global.BoolVar(&g.dryRun, "dry-run", false, "print requests that would change something instead of sending them; reads still go to the vault")
+	global.BoolVar(&g.timing, "timing", false, "print how long requests took, per endpoint, to stderr when the workflow ends")
+	return global, g
+}
+
+// startSession reuses the cached session token if there is a fresh one, and
+// logs on otherwise.
+func startSession(client *APIClient) error {
+	if client.cache != nil {
+		if token, ok := client.cache.load(client.config); ok {
+			client.token, client.cachedToken = token, true
+			return nil
+		}
+	}
+	_, err := client.Logon()
+	return err
+}
+
+// printUsage lists the global flags and the registered workflows.
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: cyberark [global options] <workflow> [options]\n\n")
+	fmt.Fprintf(os.Stderr, "Global options:\n")
+	opts := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(opts, "  --config PATH\tconfiguration file, or - for stdin (default %s)\n", defaultConfigPath())
+	fmt.Fprintf(opts, "  --profile, -p NAME\tprofile to load from a config file with profiles (default %s)\n", defaultProfile)
+	fmt.Fprintf(opts, "  --output, -o FORMAT\tdefault output of list workflows: table, json, jsonl or csv\n")
+	fmt.Fprintf(opts, "  --otp CODE\tanswer a RADIUS challenge without prompting (or set CYBERARK_OTP)\n")
+	fmt.Fprintf(opts, "  --no-cache\tlog on afresh, ignoring token_cache_ttl\n")
+	fmt.Fprintf(opts, "  --sandbox\tuse built-in sample data instead of a PVWA, for demos and trials\n")
+	fmt.Fprintf(opts, "  --deadline DURATION\tabort the workflow after this long, e.g. 10m\n")
+	fmt.Fprintf(opts, "  --max-results N\tstop list and search workflows after N results (default from max_results)\n")
+	fmt.Fprintf(opts, "  --precheck\tcheck safe permissions before the workflow changes anything\n")
+	fmt.Fprintf(opts, "  --dry-run\tprint the requests that would change something instead of sending them\n")
+	fmt.Fprintf(opts, "  --validate-server\tcheck the PVWA's version and logon methods first\n")
+	fmt.Fprintf(opts, "  --verbose, -v\tlog every request and its status to stderr\n")
+	fmt.Fprintf(opts, "  --debug\talso log headers and bodies, with secrets redacted\n")
+	fmt.Fprintf(opts, "  --timing\tsummarize request latency per endpoint when done\n")
+	fmt.Fprintf(opts, "  --header 'NAME: VALUE'\tadd a header to every request; repeatable\n")
+	fmt.Fprintf(opts, "  --allow-override-auth\tlet --header replace Authorization\n")
+	fmt.Fprintf(opts, "  --audit-reads\talso record read-only requests in audit_log_path\n")
+	fmt.Fprintf(opts, "  --version\tprint the version and build information\n")
+	opts.Flush()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, "Workflows:\n")
+	tw := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, name := range workflowNames() {
+		synopsis := ""
+		if d, ok := WorkflowRegistry[name].(DescribableWorkflow); ok {
+			synopsis = d.Synopsis()
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", name, synopsis)
+	}
+	tw.Flush()
+	fmt.Fprintf(os.Stderr, "\nRun 'cyberark <workflow> --help' for workflow options.\n")
+}
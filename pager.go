@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// listPage is the envelope of the API's paged list responses.
+type listPage[T any] struct {
+	Value    []T    `json:"value"`
+	Count    int    `json:"count"`
+	NextLink string `json:"nextLink"`
+}
+
+// listPageSize is the page size used when fetching every result.
+const listPageSize = 100
+
+// fetchPages pages through a list endpoint with offset and limit, calling
+// fn with each page of results until all have been read. It stops early,
+// with a warning on stderr, once the config's max_results cap is reached.
+func fetchPages[T any](client *APIClient, endpoint string, params url.Values, fn func([]T) error) error {
+	return pageThrough(client, endpoint, params, client.config.MaxResults, fn)
+}
+
+// fetchAll returns every result of a list endpoint. Its callers look
+// things up rather than list them for the user, so it ignores the
+// max_results cap: a truncated member list would give wrong answers.
+func fetchAll[T any](client *APIClient, endpoint string, params url.Values) ([]T, error) {
+	var all []T
+	err := pageThrough(client, endpoint, params, 0, func(page []T) error {
+		all = append(all, page...)
+		return nil
+	})
+	return all, err
+}
+
+// pageThrough implements fetchPages, reading at most maxResults results
+// when it is positive. The next page starts where the server's nextLink
+// says, or after the results read so far when there is none. A nextLink
+// that does not move forward is an error rather than an endless loop.
+// Interrupting the run aborts the next page's request.
+func pageThrough[T any](client *APIClient, endpoint string, params url.Values, maxResults int, fn func([]T) error) error {
+	q := url.Values{}
+	for k, v := range params {
+		q[k] = v
+	}
+	q.Set("limit", strconv.Itoa(listPageSize))
+	offset := 0
+	for maxResults <= 0 || offset < maxResults {
+		if maxResults > 0 {
+			q.Set("limit", strconv.Itoa(min(listPageSize, maxResults-offset)))
+		}
+		q.Set("offset", strconv.Itoa(offset))
+		data, err := client.GetWithParams(endpoint, q)
+		if err != nil {
+			return err
+		}
+		page, err := decodeResponse[listPage[T]](endpoint, data)
+		if err != nil {
+			return err
+		}
+		if maxResults > 0 && offset+len(page.Value) > maxResults {
+			page.Value = page.Value[:maxResults-offset]
+		}
+		if err := fn(page.Value); err != nil {
+			return err
+		}
+		read := offset + len(page.Value)
+		if read >= page.Count {
+			return nil
+		}
+		if maxResults > 0 && read >= maxResults {
+			warnTruncated(read, page.Count)
+			return nil
+		}
+		if page.NextLink == "" {
+			if len(page.Value) < listPageSize {
+				return nil
+			}
+			offset = read
+			continue
+		}
+		next, err := nextLinkOffset(page.NextLink)
+		if err != nil {
+			return fmt.Errorf("malformed nextLink %q from %s: %w", page.NextLink, endpoint, err)
+		}
+		if next <= offset {
+			return fmt.Errorf("malformed nextLink %q from %s: it does not move past offset %d", page.NextLink, endpoint, offset)
+		}
+		offset = next
+	}
+	return nil
+}
+
+// warnTruncated tells the user that max_results stopped a listing after
+// read of total results.
+func warnTruncated(read, total int) {
+	fmt.Fprintf(os.Stderr, "Warning: results truncated at %d of %d by max_results; narrow the query or raise --max-results (0 for no limit) to see the rest\n", read, total)
+}
+
+// nextLinkOffset returns the offset a list response's nextLink points at,
+// such as "api/Accounts?offset=100&limit=100".
+func nextLinkOffset(link string) (int, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(u.Query().Get("offset"))
+	if err != nil {
+		return 0, errors.New("it has no numeric offset")
+	}
+	return offset, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPageThroughFollowsNextLink(t *testing.T) {
+	var offsets []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset := r.URL.Query().Get("offset")
+		offsets = append(offsets, offset)
+		if offset == "0" {
+			// A short page that still has more to come.
+			fmt.Fprint(w, `{"value":[1,2],"count":3,"nextLink":"api/Accounts?offset=2&limit=100"}`)
+			return
+		}
+		fmt.Fprint(w, `{"value":[3],"count":3}`)
+	}))
+	defer srv.Close()
+
+	got, err := fetchAll[int](newTestClient(t, srv), "PasswordVault/API/Accounts", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || strings.Join(offsets, ",") != "0,2" {
+		t.Errorf("fetchAll() = %v with offsets %v, want 3 results from offsets 0,2", got, offsets)
+	}
+}
+
+func TestPageThroughRejectsNextLinkThatDoesNotAdvance(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `{"value":[1],"count":5,"nextLink":"api/Accounts?offset=0&limit=100"}`)
+	}))
+	defer srv.Close()
+
+	_, err := fetchAll[int](newTestClient(t, srv), "PasswordVault/API/Accounts", nil)
+	if err == nil || !strings.Contains(err.Error(), "malformed nextLink") {
+		t.Fatalf("fetchAll() error = %v, want a malformed nextLink error", err)
+	}
+	if calls != 1 {
+		t.Errorf("server saw %d requests, want 1", calls)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// safePermissions lists every safe member permission accepted by the
+// Safe Members API, in the order the PVWA displays them.
+var safePermissions = []string{
+	"useAccounts",
+	"retrieveAccounts",
+	"listAccounts",
+	"addAccounts",
+	"updateAccountContent",
+	"updateAccountProperties",
+	"initiateCPMAccountManagementOperations",
+	"specifyNextAccountContent",
+	"renameAccounts",
+	"deleteAccounts",
+	"unlockAccounts",
+	"manageSafe",
+	"manageSafeMembers",
+	"backupSafe",
+	"viewAuditLog",
+	"viewSafeMembers",
+	"accessWithoutConfirmation",
+	"createFolders",
+	"deleteFolders",
+	"moveAccountsAndFolders",
+	"requestsAuthorizationLevel1",
+	"requestsAuthorizationLevel2",
+}
+
+// ownerPermissions is every permission except the second authorization
+// level, which the vault does not allow together with the first.
+var ownerPermissions = without(safePermissions, "requestsAuthorizationLevel2")
+
+// builtinSafeRoles maps the predefined --role names to the permissions
+// they grant. Roles defined in the config's safe_roles take precedence.
+var builtinSafeRoles = map[string][]string{
+	"use": {
+		"useAccounts",
+		"retrieveAccounts",
+		"listAccounts",
+	},
+	// read-only can see and use everything in the safe but change nothing.
+	"read-only": {
+		"useAccounts",
+		"retrieveAccounts",
+		"listAccounts",
+		"viewAuditLog",
+		"viewSafeMembers",
+	},
+	"approver": {
+		"listAccounts",
+		"manageSafeMembers",
+		"viewSafeMembers",
+		"requestsAuthorizationLevel1",
+	},
+	"auditor": {
+		"listAccounts",
+		"viewAuditLog",
+		"viewSafeMembers",
+	},
+	"owner": ownerPermissions,
+	"full":  ownerPermissions,
+}
+
+func without(list []string, drop string) []string {
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		if s != drop {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func isSafePermission(name string) bool {
+	for _, p := range safePermissions {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSafeRoles checks that custom roles only name known permissions.
+func validateSafeRoles(roles map[string][]string) error {
+	for role, perms := range roles {
+		for _, p := range perms {
+			if !isSafePermission(p) {
+				return fmt.Errorf("config: safe role %q grants unknown permission %q", role, p)
+			}
+		}
+	}
+	return nil
+}
+
+// safeRoleNames returns the names of the built-in and custom roles.
+func safeRoleNames(custom map[string][]string) []string {
+	seen := map[string]bool{}
+	for name := range builtinSafeRoles {
+		seen[name] = true
+	}
+	for name := range custom {
+		seen[name] = true
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveSafeRole expands a role name into the full permission set sent to
+// the API, with every permission present and set to true or false.
+func resolveSafeRole(role string, custom map[string][]string) (map[string]bool, error) {
+	granted, ok := custom[role]
+	if !ok {
+		granted, ok = builtinSafeRoles[role]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown role %q (available: %s)", role, strings.Join(safeRoleNames(custom), ", "))
+	}
+
+	perms := make(map[string]bool, len(safePermissions))
+	for _, p := range safePermissions {
+		perms[p] = false
+	}
+	for _, p := range granted {
+		perms[p] = true
+	}
+	return perms, nil
+}
+
+// memberTypeName returns the Safe Members API spelling of a --member-type
+// value, which may be given in any case.
+func memberTypeName(memberType string) (string, error) {
+	switch strings.ToLower(memberType) {
+	case "user":
+		return "User", nil
+	case "group":
+		return "Group", nil
+	}
+	return "", fmt.Errorf("invalid --member-type %q: must be user or group", memberType)
+}
+
+// permissionFlagName converts a permission name such as "useAccounts" to
+// its flag form, "use-accounts". Acronyms stay together, so
+// "initiateCPMAccountManagementOperations" becomes
+// "initiate-cpm-account-management-operations".
+func permissionFlagName(perm string) string {
+	runes := []rune(perm)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower {
+				b.WriteByte('-')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// formatPermissions renders the granted permissions, one per line.
+func formatPermissions(perms map[string]bool) string {
+	var b strings.Builder
+	for _, p := range safePermissions {
+		if perms[p] {
+			fmt.Fprintf(&b, "  %s\n", p)
+		}
+	}
+	if b.Len() == 0 {
+		return "  (none)\n"
+	}
+	return b.String()
+}
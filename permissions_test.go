@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveSafeRolePresets(t *testing.T) {
+	tests := []struct {
+		role   string
+		grants []string
+		denies []string
+	}{
+		{"use", []string{"useAccounts", "retrieveAccounts", "listAccounts"}, []string{"viewAuditLog", "addAccounts"}},
+		{"read-only", []string{"useAccounts", "retrieveAccounts", "listAccounts", "viewAuditLog", "viewSafeMembers"}, []string{"addAccounts", "manageSafeMembers"}},
+		{"approver", []string{"listAccounts", "manageSafeMembers", "requestsAuthorizationLevel1"}, []string{"retrieveAccounts"}},
+		{"auditor", []string{"listAccounts", "viewAuditLog", "viewSafeMembers"}, []string{"useAccounts"}},
+		{"owner", without(safePermissions, "requestsAuthorizationLevel2"), []string{"requestsAuthorizationLevel2"}},
+		{"full", without(safePermissions, "requestsAuthorizationLevel2"), []string{"requestsAuthorizationLevel2"}},
+	}
+	for _, tt := range tests {
+		perms, err := resolveSafeRole(tt.role, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.role, err)
+			continue
+		}
+		if len(perms) != len(safePermissions) {
+			t.Errorf("%s: %d permissions, want all %d present", tt.role, len(perms), len(safePermissions))
+		}
+		for _, p := range tt.grants {
+			if !perms[p] {
+				t.Errorf("%s does not grant %s", tt.role, p)
+			}
+		}
+		for _, p := range tt.denies {
+			if perms[p] {
+				t.Errorf("%s grants %s", tt.role, p)
+			}
+		}
+	}
+}
+
+func TestResolveSafeRoleCustom(t *testing.T) {
+	custom := map[string][]string{"use": {"listAccounts"}}
+	perms, err := resolveSafeRole("use", custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !perms["listAccounts"] || perms["retrieveAccounts"] {
+		t.Errorf("custom use role not applied: %v", perms)
+	}
+	_, err = resolveSafeRole("admin", custom)
+	if err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("unknown role: error = %v, want the available roles listed", err)
+	}
+}
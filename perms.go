@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkPrivate returns an error if the file described by info can be read
+// or written by users other than its owner. what names the file in the
+// error, e.g. "config file".
+func checkPrivate(path string, info os.FileInfo, what string) error {
+	if mode := info.Mode().Perm(); mode&0o077 != 0 {
+		return fmt.Errorf("%s %s has permissions %04o; it must not be accessible by group or others (chmod 600 %s)", what, path, mode, path)
+	}
+	return nil
+}
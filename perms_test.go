@@ -0,0 +1,39 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckFilePermissions(t *testing.T) {
+	path := writeConfig(t, "{}")
+	if err := checkFilePermissions(path); err != nil {
+		t.Errorf("mode 0600: %v", err)
+	}
+	if err := os.Chmod(path, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkFilePermissions(path); err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Errorf("mode 0640: error = %v, want a chmod 600 hint", err)
+	}
+}
+
+func TestAuditLogIsPrivate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := openAuditLog(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.f.Close()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("audit log mode = %04o, want 0600", mode)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// publicSIDs are the well-known groups that cover users other than the
+// file's owner. Windows does not report POSIX permission bits, so a file
+// is private when its ACL grants none of them access.
+var publicSIDs = []struct {
+	sid  windows.WELL_KNOWN_SID_TYPE
+	name string
+}{
+	{windows.WinWorldSid, "Everyone"},
+	{windows.WinAnonymousSid, "ANONYMOUS LOGON"},
+	{windows.WinAuthenticatedUserSid, "Authenticated Users"},
+	{windows.WinBuiltinUsersSid, "Users"},
+	{windows.WinBuiltinGuestsSid, "Guests"},
+}
+
+// checkPrivate returns an error if the ACL of the file at path grants
+// access to a group of other users. what names the file in the error,
+// e.g. "config file".
+func checkPrivate(path string, info os.FileInfo, what string) error {
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return fmt.Errorf("failed to read the ACL of %s %s: %w", what, path, err)
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return fmt.Errorf("failed to read the ACL of %s %s: %w", what, path, err)
+	}
+	hint := fmt.Sprintf("icacls %s /inheritance:r /grant:r %%USERNAME%%:F", path)
+	if dacl == nil {
+		return fmt.Errorf("%s %s has no ACL, so everyone can access it (%s)", what, path, hint)
+	}
+	for i := 0; i < int(dacl.AceCount); i++ {
+		var ace *windows.ACCESS_ALLOWED_ACE
+		if err := windows.GetAce(dacl, uint32(i), &ace); err != nil {
+			return fmt.Errorf("failed to read the ACL of %s %s: %w", what, path, err)
+		}
+		if ace.Header.AceType != windows.ACCESS_ALLOWED_ACE_TYPE {
+			continue
+		}
+		sid := (*windows.SID)(unsafe.Pointer(&ace.SidStart))
+		for _, public := range publicSIDs {
+			if sid.IsWellKnown(public.sid) {
+				return fmt.Errorf("%s %s grants access to %s; it must only be accessible by you (%s)", what, path, public.name, hint)
+			}
+		}
+	}
+	return nil
+}
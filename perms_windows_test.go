@@ -0,0 +1,41 @@
+//go:build windows
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestCheckFilePermissions(t *testing.T) {
+	path := writeConfig(t, "{}")
+	// Replace the inherited ACL with one granting only the owner access.
+	setACL(t, path, "D:P(A;;FA;;;OW)")
+	if err := checkFilePermissions(path); err != nil {
+		t.Errorf("owner-only ACL: %v", err)
+	}
+	setACL(t, path, "D:P(A;;FA;;;OW)(A;;FR;;;WD)")
+	if err := checkFilePermissions(path); err == nil || !strings.Contains(err.Error(), "Everyone") {
+		t.Errorf("ACL granting Everyone read: error = %v, want it rejected", err)
+	}
+}
+
+// setACL replaces the DACL of path with the one in an SDDL string.
+func setACL(t *testing.T, path, sddl string) {
+	t.Helper()
+	sd, err := windows.SecurityDescriptorFromString(sddl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, dacl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
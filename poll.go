@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// pollOptions controls how a long-running operation is polled with --wait.
+type pollOptions struct {
+	Interval    time.Duration
+	MaxInterval time.Duration
+	Timeout     time.Duration
+}
+
+// registerFlags binds --poll-interval, --poll-max-interval and --timeout.
+func (o *pollOptions) registerFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&o.Interval, "poll-interval", 2*time.Second, "initial delay between status checks when waiting")
+	fs.DurationVar(&o.MaxInterval, "poll-max-interval", 30*time.Second, "maximum delay between status checks when waiting")
+	fs.DurationVar(&o.Timeout, "timeout", 10*time.Minute, "how long to wait for the operation to finish")
+}
+
+func (o pollOptions) validate() error {
+	if o.Interval <= 0 {
+		return errors.New("--poll-interval must be positive")
+	}
+	if o.MaxInterval < o.Interval {
+		return errors.New("--poll-max-interval must not be less than --poll-interval")
+	}
+	if o.Timeout <= 0 {
+		return errors.New("--timeout must be positive")
+	}
+	return nil
+}
+
+// PollTimeoutError is returned when an operation has not finished before
+// the wait timeout.
+type PollTimeoutError struct {
+	Timeout    time.Duration
+	LastStatus string
+}
+
+func (e *PollTimeoutError) Error() string {
+	status := e.LastStatus
+	if status == "" {
+		status = "unknown"
+	}
+	return fmt.Sprintf("timed out after %s waiting for the operation to finish (last status: %s)", e.Timeout, status)
+}
+
+// pollFunc reports the current status of an operation and whether it has
+// reached a final state.
+type pollFunc func() (status string, done bool, err error)
+
+// poll calls check until it reports done or opts.Timeout elapses, and
+// returns the last status seen. The delay between checks starts at
+// opts.Interval and doubles up to opts.MaxInterval, so quick operations
+// finish promptly without hammering the server during slow ones.
+//
+// Transport failures are treated as transient: they are reported on stderr
+// and polling continues. Any other error from check ends the wait.
+func poll(ctx context.Context, opts pollOptions, check pollFunc) (string, error) {
+	deadline := time.Now().Add(opts.Timeout)
+	delay := opts.Interval
+	var last string
+	for {
+		status, done, err := check()
+		switch {
+		case err == nil:
+			last = status
+			if done {
+				return status, nil
+			}
+		case errors.Is(err, errTransport):
+			fmt.Fprintf(os.Stderr, "Warning: status check failed, will retry: %v\n", err)
+		default:
+			return last, err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return last, &PollTimeoutError{Timeout: opts.Timeout, LastStatus: last}
+		}
+		if err := sleepContext(ctx, min(delay, remaining)); err != nil {
+			return last, err
+		}
+		delay = min(delay*2, opts.MaxInterval)
+	}
+}
+
+// CPMStatusSet lists the statuses that end a CPM operation, matched
+// ignoring case.
+type CPMStatusSet struct {
+	Success []string `json:"success,omitempty"`
+	Failure []string `json:"failure,omitempty"`
+}
+
+// defaultCPMStatuses are the final statuses the vault reports for most
+// platforms.
+var defaultCPMStatuses = CPMStatusSet{Success: []string{"success"}, Failure: []string{"failure"}}
+
+// cpmStatusesFor returns the statuses that end a CPM operation on an
+// account of platform. A list left out of the platform's cpm_statuses
+// entry falls back to the "*" entry, then to the defaults.
+func cpmStatusesFor(platform string, config map[string]CPMStatusSet) CPMStatusSet {
+	set := defaultCPMStatuses
+	for _, key := range []string{"*", platform} {
+		entry, ok := config[key]
+		if !ok {
+			continue
+		}
+		if len(entry.Success) > 0 {
+			set.Success = entry.Success
+		}
+		if len(entry.Failure) > 0 {
+			set.Failure = entry.Failure
+		}
+	}
+	return set
+}
+
+// outcome reports whether status is one of the set's success or failure
+// statuses.
+func (s CPMStatusSet) outcome(status string) (success, failure bool) {
+	has := func(list []string) bool {
+		for _, v := range list {
+			if strings.EqualFold(v, status) {
+				return true
+			}
+		}
+		return false
+	}
+	return has(s.Success), has(s.Failure)
+}
+
+// validateCPMStatuses rejects empty statuses and statuses listed as both
+// success and failure for the same platform.
+func validateCPMStatuses(config map[string]CPMStatusSet) error {
+	for platform := range config {
+		set := cpmStatusesFor(platform, config)
+		for _, list := range [][]string{set.Success, set.Failure} {
+			for _, status := range list {
+				if strings.TrimSpace(status) == "" {
+					return fmt.Errorf("config: cpm_statuses: %s has an empty status", platform)
+				}
+			}
+		}
+		for _, status := range set.Success {
+			if _, failure := set.outcome(status); failure {
+				return fmt.Errorf("config: cpm_statuses: %s lists %q as both success and failure", platform, status)
+			}
+		}
+	}
+	return nil
+}
+
+// finishedSince reports whether the CPM recorded a final status at or after
+// the given time. The status alone is not enough, since it still holds the
+// result of the previous operation until the CPM picks up the new one.
+func (s SecretManagement) finishedSince(t time.Time, statuses CPMStatusSet) bool {
+	if success, failure := statuses.outcome(s.Status); !success && !failure {
+		return false
+	}
+	latest := max(s.LastModifiedTime, s.LastVerifiedTime, s.LastReconciledTime)
+	return latest >= t.Unix()
+}
+
+// waitForCPM polls an account until the CPM operation queued at started
+// finishes. The statuses that count as finished come from the platform's
+// cpm_statuses entry. It returns an error if the operation failed or timed
+// out.
+func waitForCPM(client *APIClient, accountID string, started time.Time, opts pollOptions) (string, error) {
+	var statuses CPMStatusSet
+	platform := ""
+	status, err := poll(client.baseContext(), opts, func() (string, bool, error) {
+		account, err := getAccount(client, accountID)
+		if err != nil {
+			return "", false, err
+		}
+		platform = account.PlatformID
+		statuses = cpmStatusesFor(platform, client.config.CPMStatuses)
+		state := account.SecretManagement
+		return state.Status, state.finishedSince(started, statuses), nil
+	})
+	var timeout *PollTimeoutError
+	if errors.As(err, &timeout) && status != "" {
+		if success, failure := statuses.outcome(status); !success && !failure {
+			return status, fmt.Errorf("%w; %q is not a success or failure status for platform %s, add it to cpm_statuses if it is final",
+				err, status, platform)
+		}
+	}
+	if err != nil {
+		return status, err
+	}
+	if success, _ := statuses.outcome(status); !success {
+		return status, fmt.Errorf("CPM operation on account %s finished with status %q", accountID, status)
+	}
+	return status, nil
+}
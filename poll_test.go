@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPollBacksOffUpToTheMaxInterval(t *testing.T) {
+	opts := pollOptions{Interval: 10 * time.Millisecond, MaxInterval: 20 * time.Millisecond, Timeout: time.Minute}
+	var calls []time.Time
+	status, err := poll(context.Background(), opts, func() (string, bool, error) {
+		calls = append(calls, time.Now())
+		return "pending", len(calls) == 5, nil
+	})
+	if err != nil || status != "pending" {
+		t.Fatalf("poll = %q, %v", status, err)
+	}
+	// Waits are 10, 20, 20 and 20ms; uncapped, the last would be 80ms.
+	for i, want := range []time.Duration{10, 20, 20, 20} {
+		want *= time.Millisecond
+		if gap := calls[i+1].Sub(calls[i]); gap < want || (i == 3 && gap >= 4*want) {
+			t.Errorf("wait %d = %s, want %s", i+1, gap, want)
+		}
+	}
+}
+
+func TestPollRetriesTransportFailures(t *testing.T) {
+	opts := pollOptions{Interval: time.Millisecond, MaxInterval: time.Millisecond, Timeout: time.Minute}
+	results := []error{fmt.Errorf("%w: connection reset", errTransport), nil}
+	calls := 0
+	status, err := poll(context.Background(), opts, func() (string, bool, error) {
+		err := results[calls]
+		calls++
+		return "success", err == nil, err
+	})
+	if err != nil || status != "success" || calls != 2 {
+		t.Errorf("poll = %q, %v after %d checks, want success after a transport failure", status, err, calls)
+	}
+
+	refused := errors.New("403 Forbidden")
+	calls = 0
+	status, err = poll(context.Background(), opts, func() (string, bool, error) {
+		calls++
+		if calls == 1 {
+			return "pending", false, nil
+		}
+		return "", false, refused
+	})
+	if !errors.Is(err, refused) || status != "pending" || calls != 2 {
+		t.Errorf("poll = %q, %v after %d checks, want the refusal to end the wait with the last status", status, err, calls)
+	}
+}
+
+func TestPollTimeoutCarriesTheLastStatus(t *testing.T) {
+	opts := pollOptions{Interval: time.Millisecond, MaxInterval: 5 * time.Millisecond, Timeout: 30 * time.Millisecond}
+	calls := 0
+	_, err := poll(context.Background(), opts, func() (string, bool, error) {
+		calls++
+		if calls > 1 {
+			// A failed check does not replace the status last seen.
+			return "", false, errTransport
+		}
+		return "inProcess", false, nil
+	})
+	var timeout *PollTimeoutError
+	if !errors.As(err, &timeout) || timeout.LastStatus != "inProcess" || timeout.Timeout != opts.Timeout {
+		t.Errorf("poll = %v, want a PollTimeoutError with the last status", err)
+	}
+}
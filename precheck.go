@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// safeNeed is a set of permissions a workflow needs on one safe.
+type safeNeed struct {
+	Safe        string
+	Permissions []string
+}
+
+// precheck confirms, before a workflow changes anything, that the logged-on
+// user holds the permissions it is about to need. Workflows call it with
+// their needs right after parsing their flags; it does nothing unless the
+// global --precheck flag was given, so normal runs cost no extra requests.
+//
+// Effective permissions are those granted to the user directly or to any
+// group the user belongs to. Reading the members of a safe needs
+// viewSafeMembers, so a safe the check cannot read is reported as such.
+func (c *APIClient) precheck(needs ...safeNeed) error {
+	if !c.prechecks || len(needs) == 0 {
+		return nil
+	}
+	principals, err := currentPrincipals(c)
+	if err != nil {
+		return fmt.Errorf("--precheck: %w", err)
+	}
+
+	var problems []string
+	for _, need := range needs {
+		members, err := listSafeMembers(c, need.Safe)
+		if err != nil {
+			if status := apiStatus(err); status == http.StatusForbidden || status == http.StatusUnauthorized {
+				problems = append(problems, fmt.Sprintf("safe %s: cannot read its members to check; viewSafeMembers is needed", need.Safe))
+				continue
+			}
+			return fmt.Errorf("--precheck: failed to list members of safe %s: %w", need.Safe, err)
+		}
+		granted := map[string]bool{}
+		for _, m := range members {
+			if !principals[strings.ToLower(m.MemberName)] {
+				continue
+			}
+			for p, ok := range m.Permissions {
+				granted[p] = granted[p] || ok
+			}
+		}
+		var missing []string
+		for _, p := range need.Permissions {
+			if !granted[p] {
+				missing = append(missing, p)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			problems = append(problems, fmt.Sprintf("safe %s: missing %s", need.Safe, strings.Join(missing, ", ")))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("--precheck: %s does not have the permissions this run needs:\n  %s",
+			c.config.Username, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// currentPrincipals returns the lower-cased names the logged-on user holds
+// safe memberships under: the user name and the user's groups.
+func currentPrincipals(c *APIClient) (map[string]bool, error) {
+	if c.config.Username == "" {
+		return nil, errors.New("username must be set in the config to check permissions")
+	}
+	user, err := findUser(c, c.config.Username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the current user: %w", err)
+	}
+	// The search results leave out group memberships.
+	if user, err = getUser(c, user.ID); err != nil {
+		return nil, fmt.Errorf("failed to look up the current user: %w", err)
+	}
+	principals := map[string]bool{strings.ToLower(user.Username): true}
+	for _, g := range user.GroupsMembership {
+		principals[strings.ToLower(g.GroupName)] = true
+	}
+	return principals, nil
+}
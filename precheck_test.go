@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrecheckCombinesUserAndGroupPermissions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PasswordVault/API/Users":
+			w.Write([]byte(`{"Users":[{"id":7,"username":"svc"}]}`))
+		case "/PasswordVault/API/Users/7":
+			w.Write([]byte(`{"id":7,"username":"svc","groupsMembership":[{"groupName":"Ops Admins"}]}`))
+		case "/PasswordVault/API/Safes/Ops/Members":
+			w.Write([]byte(`{"count":3,"value":[
+				{"memberName":"svc","permissions":{"listAccounts":true}},
+				{"memberName":"ops admins","permissions":{"addAccounts":true}},
+				{"memberName":"someone-else","permissions":{"deleteAccounts":true}}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewAPIClient(&Config{BaseURL: srv.URL, Username: "svc", APISecret: "password", Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.precheck(safeNeed{"Ops", []string{"deleteAccounts"}}); err != nil {
+		t.Fatalf("precheck() without --precheck = %v, want nil", err)
+	}
+
+	client.prechecks = true
+	if err := client.precheck(safeNeed{"Ops", []string{"listAccounts", "addAccounts"}}); err != nil {
+		t.Errorf("precheck() = %v, want the user and group grants combined", err)
+	}
+	err = client.precheck(safeNeed{"Ops", []string{"addAccounts", "deleteAccounts"}})
+	if err == nil || !strings.Contains(err.Error(), "missing deleteAccounts") {
+		t.Errorf("precheck() = %v, want deleteAccounts reported missing", err)
+	}
+}
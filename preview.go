@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// bodyPreview implements --print-body and --dry-run for workflows that
+// send a JSON body, so a reviewer can approve the literal request. The
+// global --dry-run goes further: the request is answered with a made-up
+// success and the workflow carries on, see APIClient.simulate.
+type bodyPreview struct {
+	printBody bool
+	dryRun    bool
+}
+
+// registerFlags binds --print-body and --dry-run.
+func (p *bodyPreview) registerFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&p.printBody, "print-body", false, "print the JSON request body to stderr, with secrets redacted")
+	fs.BoolVar(&p.dryRun, "dry-run", false, "do not send the request")
+}
+
+// sendsNothing reports whether the request will not reach the vault,
+// because of this --dry-run or the global one.
+func (p *bodyPreview) sendsNothing(client *APIClient) bool {
+	return p.dryRun || client.dryRun
+}
+
+// show prints the request according to the flags and reports whether it
+// should be sent. Under the global --dry-run the client prints the body
+// itself, so --print-body does not print it twice.
+func (p *bodyPreview) show(client *APIClient, method, endpoint string, body interface{}) (bool, error) {
+	if p.printBody && !client.dryRun {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return false, fmt.Errorf("failed to encode request body: %w", err)
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, redactJSON(data), "", "  "); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(os.Stderr, "%s %s\n%s\n", method, endpoint, out.String())
+	}
+	if p.dryRun {
+		fmt.Fprintf(os.Stderr, "[DRY RUN] %s %s not sent\n", method, endpoint)
+		return false, nil
+	}
+	return true, nil
+}
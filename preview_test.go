@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestBodyPreviewHonorsTheGlobalDryRun(t *testing.T) {
+	dryRun := &APIClient{dryRun: true}
+	p := bodyPreview{printBody: true}
+	if !p.sendsNothing(dryRun) || p.sendsNothing(&APIClient{}) {
+		t.Error("sendsNothing ignores the global --dry-run")
+	}
+	// The client simulates the request itself, so the workflow carries on.
+	if send, err := p.show(dryRun, "POST", "PasswordVault/API/Accounts", map[string]string{"name": "x"}); !send || err != nil {
+		t.Errorf("show under the global --dry-run = %v, %v, want the request passed to the client", send, err)
+	}
+	p.dryRun = true
+	if send, _ := p.show(&APIClient{}, "POST", "PasswordVault/API/Accounts", nil); send {
+		t.Error("show with --dry-run = true, want the request held back")
+	}
+}
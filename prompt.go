@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from
+// stdin. When stdin is not a terminal it returns an error instead of
+// blocking, so scripts have to confirm with the workflow's flag.
+func confirm(prompt string) (bool, error) {
+	if !isTerminal(os.Stdin) {
+		return false, errors.New("confirmation required but stdin is not a terminal")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// ask prints prompt on stderr and returns the line typed on stdin, or an
+// error when stdin is not a terminal.
+func ask(prompt string) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", errors.New("input required but stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// askSecret is ask without echoing what is typed, for passwords and
+// one-time passcodes. Unlike ask it keeps leading and trailing spaces,
+// which can be part of a password; callers that want them gone trim the
+// answer themselves.
+func askSecret(prompt string) (string, error) {
+	if !isTerminal(os.Stdin) {
+		return "", errors.New("input required but stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	answer, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(answer), "\r"), nil
+}
+
+// secretFromStdin is the value of a secret flag that asks for the secret
+// instead of taking it from the command line, where it would be left in
+// the shell history and the process list.
+const secretFromStdin = "-"
+
+// secretFlag resolves the value of a secret flag. A value of - is read
+// with askSecret at a terminal, or as one line of piped stdin, so
+// scripts can pass a secret the same way. Any other value is returned
+// unchanged.
+func secretFlag(value, prompt string) (string, error) {
+	if value != secretFromStdin {
+		return value, nil
+	}
+	if isTerminal(os.Stdin) {
+		return askSecret(prompt)
+	}
+	return readSecretLine(os.Stdin)
+}
+
+// readSecretLine reads a secret from the first line of r, without its line
+// ending. Only the line ending is removed, since spaces can be part of a
+// password.
+func readSecretLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read the secret from stdin: %w", err)
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	if line == "" {
+		return "", errors.New("no secret on stdin")
+	}
+	return line, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadSecretLineKeepsSpaces(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{" pass word \n", " pass word "},
+		{"windows\r\nsecond line\n", "windows"},
+		{"no newline", "no newline"},
+	}
+	for _, tt := range tests {
+		got, err := readSecretLine(strings.NewReader(tt.in))
+		if err != nil || got != tt.want {
+			t.Errorf("readSecretLine(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := readSecretLine(strings.NewReader("\n")); err == nil {
+		t.Error("readSecretLine of an empty line succeeded, want an error")
+	}
+}
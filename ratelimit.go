@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket that spaces requests out to a steady rate,
+// allowing a burst of up to one second's worth after an idle spell. It is
+// shared by every copy of a client, so concurrent workers draw from the
+// same bucket.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter allowing perSecond requests a second,
+// or nil, which never waits, when perSecond is not positive.
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	burst := math.Max(1, math.Floor(perSecond))
+	return &rateLimiter{rate: perSecond, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait blocks until a request may be sent, or returns an error if ctx ends
+// first. A token reserved by a wait that is aborted is not given back.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	deficit := -l.tokens
+	l.mu.Unlock()
+	if deficit <= 0 {
+		return nil
+	}
+	return sleepContext(ctx, time.Duration(deficit/l.rate*float64(time.Second)))
+}
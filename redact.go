@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// redacted replaces sensitive values in anything the tool prints.
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are JSON keys, compared case-insensitively, whose values
+// are secrets.
+var sensitiveKeys = map[string]bool{
+	"secret":         true,
+	"password":       true,
+	"newcredentials": true,
+	"content":        true,
+}
+
+// redactJSON returns data with the values of sensitive keys replaced by
+// [REDACTED], at any depth. Keys and their order are preserved, so readers
+// still see that a secret is present and the output otherwise matches
+// what is sent. Data that is not valid JSON is returned as-is.
+func redactJSON(data []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var buf bytes.Buffer
+	if err := redactValue(dec, &buf); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
+
+// redactBody is redactJSON for request and response bodies. A body that
+// is a bare JSON string, such as a Logon token or a retrieved password,
+// is redacted whole.
+func redactBody(data []byte) []byte {
+	var s string
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) && json.Unmarshal(data, &s) == nil {
+		return []byte(`"` + redacted + `"`)
+	}
+	return redactJSON(data)
+}
+
+// redactValue copies the next JSON value from dec to buf.
+func redactValue(dec *json.Decoder, buf *bytes.Buffer) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		out, err := json.Marshal(tok)
+		buf.Write(out)
+		return err
+	}
+
+	buf.WriteRune(rune(delim))
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if delim == '[' {
+			if err := redactValue(dec, buf); err != nil {
+				return err
+			}
+			continue
+		}
+
+		keyTok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := keyTok.(string)
+		out, _ := json.Marshal(key)
+		buf.Write(out)
+		buf.WriteByte(':')
+		if sensitiveKeys[strings.ToLower(key)] {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			buf.WriteString(`"` + redacted + `"`)
+			continue
+		}
+		if err := redactValue(dec, buf); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	if delim == '{' {
+		buf.WriteByte('}')
+	} else {
+		buf.WriteByte(']')
+	}
+	return nil
+}
+
+// guardedKeys are the keys the output guard refuses to print: the
+// sensitive keys plus names that usually hold keys rather than passwords.
+var guardedKeys = map[string]bool{
+	"key":        true,
+	"apikey":     true,
+	"api_key":    true,
+	"privatekey": true,
+}
+
+func isGuardedKey(key string) bool {
+	k := strings.ToLower(key)
+	return sensitiveKeys[k] || guardedKeys[k]
+}
+
+// findSecret returns the path of the first field in a JSON document that
+// looks like it holds a secret, or "" if there is none. Empty and null
+// values are ignored, since they reveal nothing. A document that is just a
+// non-empty string counts as a secret, reported as "(response body)": that
+// is how Password/Retrieve answers, and requestlog redacts such bodies too.
+func findSecret(data []byte) string {
+	var v interface{}
+	if json.Unmarshal(data, &v) != nil {
+		return ""
+	}
+	if s, ok := v.(string); ok && s != "" {
+		return "(response body)"
+	}
+	return findSecretIn(v, "")
+}
+
+func findSecretIn(v interface{}, path string) string {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, child := range node {
+			at := strings.TrimPrefix(path+"."+k, ".")
+			if isGuardedKey(k) && child != nil && child != "" {
+				return at
+			}
+			if found := findSecretIn(child, at); found != "" {
+				return found
+			}
+		}
+	case []interface{}:
+		for i, child := range node {
+			if found := findSecretIn(child, fmt.Sprintf("%s[%d]", path, i)); found != "" {
+				return found
+			}
+		}
+	}
+	return ""
+}
+
+// errSecretInOutput is returned when the output guard stops a workflow
+// from printing what looks like a secret.
+func errSecretInOutput(path string) error {
+	return fmt.Errorf("refusing to print output containing the secret-like field %q", path)
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// column is one field of a list workflow's table and CSV output.
+type column[T any] struct {
+	Name  string
+	Value func(T) string
+}
+
+// renderer writes list results one page at a time, so output can start
+// before every page has been fetched.
+type renderer[T any] interface {
+	// write renders one page of results.
+	write(items []T) error
+	// finish flushes anything still buffered.
+	finish() error
+}
+
+// outputOptions selects how a list workflow renders its results.
+type outputOptions struct {
+	Format     string
+	Stream     bool
+	JSONIndent bool
+
+	// GroupBy names a column to group table and json output by.
+	GroupBy string
+
+	// AllowSecrets turns off the guard that stops json and jsonl output
+	// containing secret-like fields. Only workflows whose purpose is to
+	// print a secret set it.
+	AllowSecrets bool
+}
+
+// registerFlags binds --output (or -o), --stream, --group-by and --json-indent.
+// A Format already set, normally from the global --output, is the default
+// for --output.
+func (o *outputOptions) registerFlags(fs *flag.FlagSet) {
+	format := o.Format
+	if format == "" {
+		format = "table"
+	}
+	fs.StringVar(&o.Format, "output", format, "output format: table, json, jsonl or csv")
+	fs.StringVar(&o.Format, "o", format, "shorthand for --output")
+	fs.BoolVar(&o.Stream, "stream", false, "print table rows as each page arrives; columns are then only aligned within a page")
+	fs.StringVar(&o.GroupBy, "group-by", "", "group table and json output by a column, e.g. safe or platform")
+	registerJSONIndentFlag(fs, &o.JSONIndent)
+}
+
+// registerJSONIndentFlag binds --json-indent. It defaults to on when
+// stdout is a terminal, and off when output is piped to another program.
+func registerJSONIndentFlag(fs *flag.FlagSet, indent *bool) {
+	fs.BoolVar(indent, "json-indent", isTerminal(os.Stdout), "indent JSON output (default on when stdout is a terminal)")
+}
+
+// writeJSON writes a JSON document indented or compacted, followed by a
+// newline.
+func writeJSON(w io.Writer, data []byte, indent bool) error {
+	var buf bytes.Buffer
+	var err error
+	if indent {
+		err = json.Indent(&buf, data, "", "  ")
+	} else {
+		err = json.Compact(&buf, data)
+	}
+	if err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// resultChecks are the --fail-if-empty and --fail-if-nonempty flags of
+// list and search workflows, for scripts that alert on the result count.
+type resultChecks struct {
+	failIfEmpty    bool
+	failIfNonEmpty bool
+}
+
+func (r *resultChecks) registerFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&r.failIfEmpty, "fail-if-empty", false, fmt.Sprintf("exit with status %d when nothing is found", exitEmpty))
+	fs.BoolVar(&r.failIfNonEmpty, "fail-if-nonempty", false, fmt.Sprintf("exit with status %d when anything is found", exitNonEmpty))
+}
+
+// check returns an exitError if the number of results found trips one of
+// the flags.
+func (r resultChecks) check(found int) error {
+	switch {
+	case r.failIfEmpty && found == 0:
+		return &exitError{code: exitEmpty, msg: "No results found (--fail-if-empty)"}
+	case r.failIfNonEmpty && found > 0:
+		return &exitError{code: exitNonEmpty, msg: fmt.Sprintf("%d results found (--fail-if-nonempty)", found)}
+	}
+	return nil
+}
+
+// newRenderer returns the renderer for o.Format.
+//
+// json, jsonl and csv rows are written as each page arrives. An aligned
+// table needs every row to size its columns, so it is buffered until
+// finish unless --stream trades alignment across pages for earlier output.
+func newRenderer[T any](o outputOptions, w io.Writer, columns []column[T]) (renderer[T], error) {
+	if o.GroupBy != "" {
+		return newGroupedRenderer(o, w, columns)
+	}
+	switch o.Format {
+	case "table":
+		return &tableRenderer[T]{tw: tabwriter.NewWriter(w, 0, 4, 2, ' ', 0), columns: columns, stream: o.Stream}, nil
+	case "json":
+		return &jsonRenderer[T]{w: w, indent: o.JSONIndent, allowSecrets: o.AllowSecrets}, nil
+	case "jsonl":
+		return &jsonlRenderer[T]{w: w, allowSecrets: o.AllowSecrets}, nil
+	case "csv":
+		return &csvRenderer[T]{cw: csv.NewWriter(w), columns: columns}, nil
+	}
+	return nil, validateFormat(o.Format)
+}
+
+// validateFormat checks an --output value.
+func validateFormat(format string) error {
+	switch format {
+	case "table", "json", "jsonl", "csv":
+		return nil
+	}
+	return fmt.Errorf("invalid --output %q: must be table, json, jsonl or csv", format)
+}
+
+// columnKey is the name a column is selected by on the command line: its
+// heading in lower case, with spaces written as dashes.
+func columnKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+}
+
+// groupedRenderer implements --group-by. Every result is needed before
+// the groups are known, so it buffers them all until finish.
+type groupedRenderer[T any] struct {
+	o       outputOptions
+	w       io.Writer
+	columns []column[T]
+	by      int
+	items   []T
+}
+
+func newGroupedRenderer[T any](o outputOptions, w io.Writer, columns []column[T]) (renderer[T], error) {
+	if o.Format != "table" && o.Format != "json" {
+		return nil, fmt.Errorf("--group-by works with --output table or json, not %s", o.Format)
+	}
+	if o.Stream {
+		return nil, errors.New("--group-by and --stream cannot be combined")
+	}
+	want := columnKey(strings.ReplaceAll(o.GroupBy, "_", "-"))
+	keys := make([]string, len(columns))
+	for i, c := range columns {
+		keys[i] = columnKey(c.Name)
+		if keys[i] == want {
+			return &groupedRenderer[T]{o: o, w: w, columns: columns, by: i}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid --group-by %q: must be one of %s", o.GroupBy, strings.Join(keys, ", "))
+}
+
+func (r *groupedRenderer[T]) write(items []T) error {
+	r.items = append(r.items, items...)
+	return nil
+}
+
+func (r *groupedRenderer[T]) finish() error {
+	groups := map[string][]T{}
+	for _, item := range r.items {
+		key := r.columns[r.by].Value(item)
+		groups[key] = append(groups[key], item)
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if r.o.Format == "json" {
+		data, err := marshalGuarded(groups, r.o.AllowSecrets)
+		if err != nil {
+			return err
+		}
+		return writeJSON(r.w, data, r.o.JSONIndent)
+	}
+
+	// The grouped column is the same for every row of a group, so it
+	// moves into the group's heading.
+	var rest []column[T]
+	rest = append(rest, r.columns[:r.by]...)
+	rest = append(rest, r.columns[r.by+1:]...)
+	tw := tabwriter.NewWriter(r.w, 0, 4, 2, ' ', 0)
+	for i, key := range keys {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "%s: %s (%d)\n", r.columns[r.by].Name, key, len(groups[key]))
+		table := &tableRenderer[T]{tw: tw, columns: rest}
+		if err := table.write(groups[key]); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+type tableRenderer[T any] struct {
+	tw         *tabwriter.Writer
+	columns    []column[T]
+	stream     bool
+	headerDone bool
+}
+
+func (r *tableRenderer[T]) write(items []T) error {
+	if !r.headerDone {
+		names := make([]string, len(r.columns))
+		for i, c := range r.columns {
+			names[i] = c.Name
+		}
+		fmt.Fprintln(r.tw, strings.Join(names, "\t"))
+		r.headerDone = true
+	}
+	values := make([]string, len(r.columns))
+	for _, item := range items {
+		for i, c := range r.columns {
+			values[i] = c.Value(item)
+		}
+		fmt.Fprintln(r.tw, strings.Join(values, "\t"))
+	}
+	if r.stream {
+		return r.tw.Flush()
+	}
+	return nil
+}
+
+func (r *tableRenderer[T]) finish() error {
+	return r.tw.Flush()
+}
+
+// marshalGuarded marshals a record, refusing records that carry a secret
+// unless allowSecrets is set. The table and CSV renderers print only their
+// columns, so only the JSON renderers need this.
+func marshalGuarded(item interface{}, allowSecrets bool) ([]byte, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	if !allowSecrets {
+		if path := findSecret(data); path != "" {
+			return nil, errSecretInOutput(path)
+		}
+	}
+	return data, nil
+}
+
+// jsonRenderer writes the results as a single JSON array, emitting each
+// element as it arrives rather than marshaling the whole list at the end.
+type jsonRenderer[T any] struct {
+	w            io.Writer
+	indent       bool
+	allowSecrets bool
+	written      int
+}
+
+func (r *jsonRenderer[T]) write(items []T) error {
+	for _, item := range items {
+		data, err := marshalGuarded(item, r.allowSecrets)
+		if err != nil {
+			return err
+		}
+		sep := ","
+		if r.written == 0 {
+			sep = "["
+		}
+		if r.indent {
+			var buf bytes.Buffer
+			if err := json.Indent(&buf, data, "  ", "  "); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(r.w, "%s\n  %s", sep, buf.Bytes())
+		} else {
+			_, err = fmt.Fprintf(r.w, "%s%s", sep, data)
+		}
+		if err != nil {
+			return err
+		}
+		r.written++
+	}
+	return nil
+}
+
+func (r *jsonRenderer[T]) finish() error {
+	var err error
+	switch {
+	case r.written == 0:
+		_, err = fmt.Fprintln(r.w, "[]")
+	case r.indent:
+		_, err = fmt.Fprintln(r.w, "\n]")
+	default:
+		_, err = fmt.Fprintln(r.w, "]")
+	}
+	return err
+}
+
+// jsonlRenderer writes each result as a JSON object on its own line. It
+// ignores the columns and writes the whole record.
+type jsonlRenderer[T any] struct {
+	w            io.Writer
+	allowSecrets bool
+}
+
+func (r *jsonlRenderer[T]) write(items []T) error {
+	for _, item := range items {
+		data, err := marshalGuarded(item, r.allowSecrets)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(r.w, "%s\n", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *jsonlRenderer[T]) finish() error {
+	return nil
+}
+
+type csvRenderer[T any] struct {
+	cw         *csv.Writer
+	columns    []column[T]
+	headerDone bool
+}
+
+func (r *csvRenderer[T]) write(items []T) error {
+	if !r.headerDone {
+		names := make([]string, len(r.columns))
+		for i, c := range r.columns {
+			names[i] = strings.ToLower(strings.ReplaceAll(c.Name, " ", "_"))
+		}
+		r.cw.Write(names)
+		r.headerDone = true
+	}
+	for _, item := range items {
+		values := make([]string, len(r.columns))
+		for i, c := range r.columns {
+			values[i] = c.Value(item)
+		}
+		r.cw.Write(values)
+	}
+	r.cw.Flush()
+	return r.cw.Error()
+}
+
+func (r *csvRenderer[T]) finish() error {
+	r.cw.Flush()
+	return r.cw.Error()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sensitiveHeaders are the request headers whose values are never logged.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// requestLogger traces requests for the global --verbose and --debug
+// flags: one line per request, and with bodies also the request headers
+// and both bodies. Header values and body fields that hold secrets are
+// redacted, so a trace can be shared when asking for help.
+type requestLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	bodies bool
+}
+
+// record traces one attempt at a request. Each attempt is written in one
+// piece, so the traces of concurrent requests do not interleave.
+func (l *requestLogger) record(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, err error, elapsed time.Duration) {
+	if l == nil {
+		return
+	}
+	var buf bytes.Buffer
+	outcome := ""
+	if err != nil {
+		outcome = "failed: " + err.Error()
+	} else {
+		outcome = resp.Status
+	}
+	fmt.Fprintf(&buf, "%s %s -> %s (%s)\n", req.Method, req.URL, outcome, elapsed.Round(time.Millisecond))
+	if l.bodies {
+		names := make([]string, 0, len(req.Header))
+		for name := range req.Header {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value := strings.Join(req.Header[name], ", ")
+			if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+				value = redacted
+			}
+			fmt.Fprintf(&buf, "  > %s: %s\n", name, value)
+		}
+		switch {
+		case len(reqBody) == 0:
+		case strings.HasSuffix(req.URL.Path, "/Password/Retrieve"):
+			// A retrieval's reason and ticket say which credential was
+			// checked out and why, so they stay out of traces with it.
+			fmt.Fprintf(&buf, "  > %s\n", redacted)
+		default:
+			fmt.Fprintf(&buf, "  > %s\n", redactBody(reqBody))
+		}
+		if len(respBody) > 0 {
+			fmt.Fprintf(&buf, "  < %s\n", bytes.TrimSpace(redactBody(respBody)))
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(buf.Bytes())
+}
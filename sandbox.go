@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// sandboxBaseURL is the vault address shown in sandbox mode. The .example
+// domain cannot resolve, and nothing is sent there anyway.
+const sandboxBaseURL = "https://pvwa.sandbox.example"
+
+// sandboxConfig returns the configuration used by --sandbox in place of
+// the config file.
+func sandboxConfig() (*Config, error) {
+	config := &Config{BaseURL: sandboxBaseURL, Username: "sandbox-user", APISecret: "sandbox"}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Sample data served in sandbox mode. Every name is made up; the safes and
+// accounts only exist to give the read workflows something to show.
+var (
+	sandboxSafes = []Safe{
+		{SafeURLID: "Demo-Linux", SafeName: "Demo-Linux", SafeNumber: 101, Description: "Sample Linux root accounts", ManagingCPM: "PasswordManager", NumberOfDaysRetention: intPtr(7)},
+		{SafeURLID: "Demo-Windows", SafeName: "Demo-Windows", SafeNumber: 102, Description: "Sample Windows admin accounts", ManagingCPM: "PasswordManager", NumberOfDaysRetention: intPtr(7)},
+	}
+	sandboxMembers = []SafeMember{
+		{MemberName: "Administrator", MemberType: "User", IsPredefinedUser: true, Permissions: map[string]bool{"listAccounts": true, "manageSafe": true}},
+		{MemberName: "demo-ops", MemberType: "Group", Permissions: map[string]bool{"listAccounts": true, "useAccounts": true}},
+	}
+	sandboxAccounts = []Account{
+		{ID: "101_1", Name: "demo-linux-01-root", Address: "linux-01.demo.example", UserName: "root", PlatformID: "UnixSSH", SafeName: "Demo-Linux", SecretType: "password",
+			LastUsedTime: 1700000000, SecretManagement: SecretManagement{AutomaticManagementEnabled: true, Status: "success", LastModifiedTime: 1700000000}},
+		{ID: "101_2", Name: "demo-linux-02-root", Address: "linux-02.demo.example", UserName: "root", PlatformID: "UnixSSH", SafeName: "Demo-Linux", SecretType: "password",
+			SecretManagement: SecretManagement{AutomaticManagementEnabled: true, Status: "failure", LastModifiedTime: 1700003600}},
+		{ID: "102_1", Name: "demo-win-01-admin", Address: "win-01.demo.example", UserName: "Administrator", PlatformID: "WinServerLocal", SafeName: "Demo-Windows", SecretType: "password",
+			SecretManagement: SecretManagement{ManualManagementReason: "Sample manually managed account"}},
+	}
+	sandboxPlatforms = []PlatformDetails{
+		sandboxPlatform("UnixSSH", "Unix via SSH", "*NIX", []string{"Address", "Username"}, []string{"Port"}),
+		sandboxPlatform("WinServerLocal", "Windows Server Local Accounts", "Windows", []string{"Address", "Username"}, []string{"LogonDomain"}),
+	}
+	sandboxCPMs = []Component{
+		{UserName: "PasswordManager", Version: "14.0", IP: "192.0.2.10", IsLoggedOn: true, LastLogonDate: 1700000000},
+	}
+	sandboxServer = ServerInfo{ServerName: "Sandbox Vault", ExternalVersion: "14.0.0", AuthenticationMethods: []AuthenticationMethod{{ID: "CyberArk", DisplayName: "CyberArk", Enabled: true}}}
+)
+
+func intPtr(n int) *int { return &n }
+
+// sandboxPlatform returns an active sample platform with the given account
+// properties.
+func sandboxPlatform(id, name, systemType string, required, optional []string) PlatformDetails {
+	p := PlatformDetails{PlatformID: id}
+	p.General.Name, p.General.SystemType, p.General.PlatformType, p.General.Active = name, systemType, "regular", true
+	for _, n := range required {
+		p.Properties.Required = append(p.Properties.Required, PlatformProperty{Name: n, DisplayName: n})
+	}
+	for _, n := range optional {
+		p.Properties.Optional = append(p.Properties.Optional, PlatformProperty{Name: n, DisplayName: n})
+	}
+	return p
+}
+
+// sandboxTransport answers requests from the sample data instead of the
+// network. Requests that would change something get a plausible response
+// and a note on stderr that they were only simulated.
+type sandboxTransport struct{}
+
+func (sandboxTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := strings.TrimPrefix(req.URL.Path, "/PasswordVault/")
+	status, body := http.StatusOK, interface{}(nil)
+	if req.Method == http.MethodGet {
+		status, body = sandboxGet(endpoint, req.URL.Query())
+	} else {
+		status, body = sandboxChange(req, endpoint)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+		Request:    req,
+	}, nil
+}
+
+// sandboxGet serves a read from the sample data.
+func sandboxGet(endpoint string, query url.Values) (int, interface{}) {
+	parts := strings.Split(strings.TrimPrefix(endpoint, "API/"), "/")
+	for i, p := range parts {
+		parts[i], _ = url.PathUnescape(p)
+	}
+	switch {
+	case endpoint == "WebServices/PIMServices.svc/Server":
+		return http.StatusOK, sandboxServer
+	case len(parts) == 1 && parts[0] == "Safes":
+		return http.StatusOK, sandboxPage(sandboxSafes, query)
+	case len(parts) == 2 && parts[0] == "Safes":
+		for _, s := range sandboxSafes {
+			if strings.EqualFold(s.SafeName, parts[1]) {
+				return http.StatusOK, s
+			}
+		}
+		return sandboxNotFound("Safe " + parts[1])
+	case len(parts) == 3 && parts[0] == "Safes" && parts[2] == "Members":
+		return http.StatusOK, sandboxPage(sandboxMembers, query)
+	case len(parts) == 1 && parts[0] == "Accounts":
+		return http.StatusOK, sandboxPage(sandboxFilterAccounts(query), query)
+	case len(parts) == 2 && parts[0] == "Accounts":
+		for _, a := range sandboxAccounts {
+			if a.ID == parts[1] {
+				return http.StatusOK, a
+			}
+		}
+		return sandboxNotFound("Account " + parts[1])
+	case len(parts) == 1 && parts[0] == "Platforms":
+		platforms := make([]Platform, len(sandboxPlatforms))
+		for i, d := range sandboxPlatforms {
+			g := &platforms[i].General
+			g.ID, g.Name, g.SystemType, g.PlatformType, g.Active = d.PlatformID, d.General.Name, d.General.SystemType, d.General.PlatformType, d.General.Active
+		}
+		return http.StatusOK, map[string]interface{}{"Platforms": platforms, "Total": len(platforms)}
+	case len(parts) == 2 && parts[0] == "Platforms":
+		for _, d := range sandboxPlatforms {
+			if strings.EqualFold(d.PlatformID, parts[1]) {
+				return http.StatusOK, d
+			}
+		}
+		return sandboxNotFound("Platform " + parts[1])
+	case len(parts) == 2 && parts[0] == "ComponentsMonitoringDetails" && parts[1] == "CPM":
+		return http.StatusOK, map[string]interface{}{"ComponentsDetails": sandboxCPMs}
+	}
+	return sandboxNotFound(endpoint)
+}
+
+// sandboxFilterAccounts applies the safeName filter and keyword search of
+// GET /Accounts to the sample accounts.
+func sandboxFilterAccounts(query url.Values) []Account {
+	safe, _ := strings.CutPrefix(query.Get("filter"), "safeName eq ")
+	words := strings.Fields(strings.ToLower(query.Get("search")))
+	var out []Account
+	for _, a := range sandboxAccounts {
+		if safe != "" && !strings.EqualFold(a.SafeName, safe) {
+			continue
+		}
+		text := strings.ToLower(strings.Join([]string{a.Name, a.Address, a.UserName, a.PlatformID}, " "))
+		matches := true
+		for _, w := range words {
+			if !strings.Contains(text, w) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// sandboxPage returns the offset and limit slice of items in the list
+// envelope pageThrough reads.
+func sandboxPage[T any](items []T, query url.Values) listPage[T] {
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = len(items)
+	}
+	start := min(max(offset, 0), len(items))
+	end := min(start+limit, len(items))
+	return listPage[T]{Value: items[start:end], Count: len(items)}
+}
+
+func sandboxNotFound(what string) (int, interface{}) {
+	return http.StatusNotFound, map[string]string{"ErrorCode": "SANDBOX404", "ErrorMessage": what + " does not exist in the sandbox"}
+}
+
+// sandboxChange simulates a request that would change something. Logon
+// returns a token and Logoff succeeds quietly; anything else gets
+// simulatedResponse.
+func sandboxChange(req *http.Request, endpoint string) (int, interface{}) {
+	switch {
+	case strings.HasSuffix(endpoint, "/Logon"):
+		return http.StatusOK, "sandbox-session-token"
+	case strings.HasSuffix(endpoint, "/Logoff"):
+		return http.StatusOK, nil
+	}
+	fmt.Fprintf(os.Stderr, "[SANDBOX] %s %s simulated; nothing was changed\n", req.Method, strings.TrimPrefix(req.URL.Path, "/"))
+	var data []byte
+	if req.Body != nil {
+		data, _ = io.ReadAll(req.Body)
+	}
+	return http.StatusOK, simulatedResponse("sandbox", req.Method, endpoint, data)
+}
+
+// simulatedResponse is the body of a successful response to a request
+// that was not really sent. Retrieving a password returns a made-up one
+// named after label. Anything else echoes the request body back, with an
+// ID when creating something, which is what workflows read from the real
+// responses.
+func simulatedResponse(label, method, endpoint string, data []byte) interface{} {
+	if strings.HasSuffix(endpoint, "/Password/Retrieve") {
+		return label + "-secret"
+	}
+	var body map[string]interface{}
+	json.Unmarshal(data, &body)
+	if body == nil {
+		return map[string]interface{}{}
+	}
+	if method == http.MethodPost {
+		body["id"] = label + "_1"
+	}
+	return body
+}
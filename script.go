@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scriptRecorder writes the individual cyberark commands that make up a
+// bulk run to a shell script, giving change reviews a record of the batch
+// that can also be replayed. A nil recorder records nothing.
+type scriptRecorder struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	w    *bufio.Writer
+}
+
+// scriptSecretFlags are flags whose values are never written to a script.
+// Each is replaced by a placeholder that reads the value from stdin when
+// the script runs.
+var scriptSecretFlags = map[string]bool{"--secret": true}
+
+// newScriptRecorder creates the script at path for a run of workflow. It
+// returns a nil recorder when path is empty.
+func newScriptRecorder(path, workflow string) (*scriptRecorder, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create script: %w", err)
+	}
+	r := &scriptRecorder{path: path, f: f, w: bufio.NewWriter(f)}
+	fmt.Fprintf(r.w, "#!/bin/sh\n# Commands recorded by cyberark %s on %s.\n", workflow, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(r.w, "# Secrets are never recorded; any \"$(head -n 1)\" reads one from stdin.\nset -e\n\n")
+	return r, nil
+}
+
+// record adds one cyberark command line to the script.
+func (r *scriptRecorder) record(args ...string) {
+	if r == nil {
+		return
+	}
+	words := make([]string, 0, len(args)+1)
+	words = append(words, "cyberark")
+	for i, arg := range args {
+		if i > 0 && scriptSecretFlags[args[i-1]] {
+			words = append(words, `"$(head -n 1)"`)
+			continue
+		}
+		words = append(words, shellQuote(arg))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintln(r.w, strings.Join(words, " "))
+}
+
+// close flushes and closes the script.
+func (r *scriptRecorder) close() error {
+	if r == nil {
+		return nil
+	}
+	err := r.w.Flush()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write script %s: %w", r.path, err)
+	}
+	fmt.Fprintf(os.Stderr, "Recorded commands in %s\n", r.path)
+	return nil
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,-]+$`)
+
+// shellQuote quotes s for a POSIX shell, leaving it bare when that is safe.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
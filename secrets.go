@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// secretResolvers maps a reference scheme allowed in api_secret, as in
+// scheme://ref, to the function that fetches the secret it names. Optional
+// resolvers register themselves from files behind build tags.
+var secretResolvers = map[string]func(ref string) (string, error){}
+
+// optionalSecretSchemes names the build tag that provides each optional
+// scheme, so a build without it can say how to get one that has it.
+var optionalSecretSchemes = map[string]string{
+	"aws-imds": "aws",
+}
+
+// secretCommandTimeout bounds how long an exec: api_secret command may run.
+const secretCommandTimeout = 30 * time.Second
+
+// resolveSecret returns the secret named by an env:VAR, exec:command or
+// scheme://ref value, or value itself when it is not a reference.
+func resolveSecret(value string) (string, error) {
+	if name, ok := strings.CutPrefix(value, "env:"); ok {
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("config: api_secret: environment variable %s is not set", name)
+		}
+		return secret, nil
+	}
+	if command, ok := strings.CutPrefix(value, "exec:"); ok {
+		secret, err := runSecretCommand(command)
+		if err != nil {
+			return "", fmt.Errorf("config: api_secret: %w", err)
+		}
+		return secret, nil
+	}
+
+	scheme, ref, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+	if resolve, ok := secretResolvers[scheme]; ok {
+		secret, err := resolve(ref)
+		if err != nil {
+			return "", fmt.Errorf("config: api_secret: %w", err)
+		}
+		return secret, nil
+	}
+	if tag, ok := optionalSecretSchemes[scheme]; ok {
+		return "", fmt.Errorf("config: api_secret uses %s://, which this build does not support (rebuild with -tags %s)", scheme, tag)
+	}
+	return value, nil
+}
+
+// runSecretCommand runs an exec: command and returns its trimmed stdout.
+// The command is split on spaces and run directly, not through a shell.
+func runSecretCommand(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("exec: needs a command")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("command %s failed: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("command %s failed: %w", args[0], err)
+	}
+	secret := strings.TrimSpace(string(out))
+	if secret == "" {
+		return "", fmt.Errorf("command %s printed nothing", args[0])
+	}
+	return secret, nil
+}
@@ -0,0 +1,72 @@
+//go:build aws
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() {
+	secretResolvers["aws-imds"] = fetchIMDS
+}
+
+// imdsIdentityPath is the instance identity document signed by AWS, which
+// a tenant fronted by AWS can verify without any stored secret.
+const imdsIdentityPath = "dynamic/instance-identity/pkcs7"
+
+// fetchIMDS reads a path under latest/ from the EC2 instance metadata
+// service using an IMDSv2 session token. An empty path fetches the signed
+// instance identity document. AWS_EC2_METADATA_SERVICE_ENDPOINT overrides
+// the endpoint as it does for the AWS SDKs.
+func fetchIMDS(path string) (string, error) {
+	if path == "" {
+		path = imdsIdentityPath
+	}
+	endpoint := strings.TrimSuffix(os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"), "/")
+	if endpoint == "" {
+		endpoint = "http://169.254.169.254"
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	req, err := http.NewRequest(http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := imdsDo(client, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get an instance metadata token: %w", err)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, endpoint+"/latest/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	value, err := imdsDo(client, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to read instance metadata %s: %w", path, err)
+	}
+	return value, nil
+}
+
+func imdsDo(client *http.Client, req *http.Request) (string, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerInfo is the response of the PVWA's unauthenticated server
+// information endpoint.
+type ServerInfo struct {
+	ServerName            string                 `json:"ServerName"`
+	ServerID              string                 `json:"ServerId"`
+	ExternalVersion       string                 `json:"ExternalVersion"`
+	AuthenticationMethods []AuthenticationMethod `json:"AuthenticationMethods"`
+}
+
+// AuthenticationMethod is a logon method offered by the PVWA.
+type AuthenticationMethod struct {
+	ID          string `json:"Id"`
+	DisplayName string `json:"DisplayName"`
+	Enabled     bool   `json:"Enabled"`
+}
+
+// minServerVersion is the oldest PVWA whose v2 API covers the safe and
+// safe member endpoints the workflows use.
+var minServerVersion = []int{12, 2}
+
+// getServerInfo fetches the PVWA's version and logon methods.
+func getServerInfo(client *APIClient) (*ServerInfo, error) {
+	info, err := GetInto[ServerInfo](client, "PasswordVault/WebServices/PIMServices.svc/Server")
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// serverWarnings compares the server's capabilities with what the client
+// is configured to use, and returns what does not match along with how to
+// fix it.
+func serverWarnings(info *ServerInfo, config *Config) []string {
+	var warnings []string
+	if v := parseVersion(info.ExternalVersion); v != nil && compareVersions(v, minServerVersion) < 0 {
+		warnings = append(warnings, fmt.Sprintf("PVWA %s is older than %d.%d; safe and safe member workflows need the v2 API from that release",
+			info.ExternalVersion, minServerVersion[0], minServerVersion[1]))
+	}
+	if len(info.AuthenticationMethods) > 0 {
+		method := config.authMethodName()
+		enabled := false
+		for _, m := range info.AuthenticationMethods {
+			if strings.EqualFold(m.ID, method) && m.Enabled {
+				enabled = true
+			}
+		}
+		if !enabled {
+			warnings = append(warnings, fmt.Sprintf("%s authentication is not enabled on this PVWA, so logon will fail; ask the vault admin to enable it or change auth_method", method))
+		}
+	}
+	return warnings
+}
+
+// validateServer prints the server's identity and any capability
+// warnings to stderr. Only failing to reach the server is an error.
+func validateServer(client *APIClient) error {
+	info, err := getServerInfo(client)
+	if err != nil {
+		return fmt.Errorf("failed to query server information from %s: %w", client.config.BaseURL, err)
+	}
+	printServerChecks(client, info)
+	return nil
+}
+
+// printServerChecks prints what validateServer reports about info.
+func printServerChecks(client *APIClient, info *ServerInfo) {
+	fmt.Fprintf(os.Stderr, "Server %s, PVWA version %s\n", valueOr(info.ServerName, "(unnamed)"), valueOr(info.ExternalVersion, "unknown"))
+	if cert := client.certs.certificate(); cert != nil {
+		fmt.Fprintf(os.Stderr, "TLS certificate %s expires on %s (%s)\n", cert.Subject.CommonName,
+			cert.NotAfter.UTC().Format(time.RFC3339), describeExpiry(time.Until(cert.NotAfter)))
+	}
+	for _, w := range serverWarnings(info, client.config) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+}
+
+// parseVersion splits a dotted version such as "12.6.0" into numbers. It
+// returns nil if any part is not a number.
+func parseVersion(s string) []int {
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ".")
+	v := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil
+		}
+		v[i] = n
+	}
+	return v
+}
+
+// compareVersions returns -1, 0 or 1 as a is older than, equal to or
+// newer than b. Missing trailing parts count as zero.
+func compareVersions(a, b []int) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// requestTimings collects how long requests take, per endpoint, for the
+// global --timing flag. It is shared by every copy of the client, so the
+// workers of a concurrent workflow all record into it.
+type requestTimings struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointTiming
+}
+
+// endpointTiming is the latency of every attempt at one method and path.
+type endpointTiming struct {
+	count    int
+	total    time.Duration
+	min, max time.Duration
+}
+
+// record adds one attempt at req that took elapsed. The query string is
+// left out of the key, so the pages of a list count as one endpoint,
+// while the path keeps the safe or account being asked about.
+func (t *requestTimings) record(req *http.Request, elapsed time.Duration) {
+	if t == nil {
+		return
+	}
+	key := req.Method + " " + strings.TrimPrefix(req.URL.Path, "/")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.endpoints == nil {
+		t.endpoints = map[string]*endpointTiming{}
+	}
+	e := t.endpoints[key]
+	if e == nil {
+		e = &endpointTiming{min: elapsed, max: elapsed}
+		t.endpoints[key] = e
+	}
+	e.count++
+	e.total += elapsed
+	e.min = min(e.min, elapsed)
+	e.max = max(e.max, elapsed)
+}
+
+// write prints the summary table, slowest endpoint in total first.
+func (t *requestTimings) write(w io.Writer) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.endpoints) == 0 {
+		_, err := fmt.Fprintln(w, "Timing: no requests were sent")
+		return err
+	}
+	keys := make([]string, 0, len(t.endpoints))
+	for key := range t.endpoints {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := t.endpoints[keys[i]], t.endpoints[keys[j]]
+		if a.total != b.total {
+			return a.total > b.total
+		}
+		return keys[i] < keys[j]
+	})
+
+	round := func(d time.Duration) string { return d.Round(time.Millisecond).String() }
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tCOUNT\tTOTAL\tMIN\tMAX\tAVG")
+	var count int
+	var total time.Duration
+	for _, key := range keys {
+		e := t.endpoints[key]
+		count += e.count
+		total += e.total
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", key, e.count, round(e.total), round(e.min), round(e.max), round(e.total/time.Duration(e.count)))
+	}
+	fmt.Fprintf(tw, "all requests\t%d\t%s\t\t\t%s\n", count, round(total), round(total/time.Duration(count)))
+	return tw.Flush()
+}
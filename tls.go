@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// buildTLSConfig returns the TLS settings for requests to the vault: the
+// trusted roots, the client certificate for mutual TLS if one is
+// configured, and whether to verify the server at all. Server certificates
+// are reported to watch after they are verified, or as soon as they are
+// received with InsecureSkipVerify.
+func buildTLSConfig(config *Config, watch *certWatch) (*tls.Config, error) {
+	pool, err := buildCertPool(config)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		RootCAs:            pool,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		VerifyConnection:   watch.verifyConnection,
+	}
+	if config.ClientCertPath != "" {
+		cert, err := loadClientCertificate(config.ClientCertPath, config.ClientKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if config.InsecureSkipVerify {
+		fmt.Fprintln(os.Stderr, "WARNING: insecure_skip_verify is set: the vault's TLS certificate is NOT verified,")
+		fmt.Fprintln(os.Stderr, "WARNING: so anyone on the network path can impersonate it and capture credentials.")
+	}
+	return tlsConfig, nil
+}
+
+// loadClientCertificate loads a PEM certificate and its private key for
+// mutual TLS.
+func loadClientCertificate(certPath, keyPath string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load client certificate %s: %w", certPath, err)
+	}
+	return cert, nil
+}
+
+// certWatch remembers the server certificate from the first TLS handshake
+// and warns once if it expires soon. Internal PVWA certificates tend to
+// expire unnoticed, and the first sign is otherwise an outage.
+type certWatch struct {
+	warnWithin time.Duration // 0 disables the warning
+
+	mu   sync.Mutex
+	leaf *x509.Certificate
+}
+
+func (w *certWatch) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.leaf != nil {
+		return nil
+	}
+	w.leaf = cs.PeerCertificates[0]
+	if left := time.Until(w.leaf.NotAfter); w.warnWithin > 0 && left < w.warnWithin {
+		fmt.Fprintf(os.Stderr, "Warning: the TLS certificate of %s expires on %s (%s); renew it before requests start failing\n",
+			cs.ServerName, w.leaf.NotAfter.UTC().Format(time.RFC3339), describeExpiry(left))
+	}
+	return nil
+}
+
+// certificate returns the server certificate seen so far, or nil before
+// the first TLS handshake.
+func (w *certWatch) certificate() *x509.Certificate {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.leaf
+}
+
+// describeExpiry phrases the time left on a certificate.
+func describeExpiry(left time.Duration) string {
+	if left < 0 {
+		return "already expired"
+	}
+	return fmt.Sprintf("in %d days", int(left.Hours()/24))
+}
+
+// buildCertPool returns the roots used to verify the server. By default
+// the CA bundle at CACertPath is added to the system roots, so public and
+// internal CAs are both trusted. CustomCertsOnly trusts the bundle alone,
+// and SystemCertsOnly ignores custom CAs entirely. A nil pool means the
+// system roots.
+func buildCertPool(config *Config) (*x509.CertPool, error) {
+	switch {
+	case config.SystemCertsOnly && config.CustomCertsOnly:
+		return nil, errors.New("config: system_certs_only and custom_certs_only are mutually exclusive")
+	case config.SystemCertsOnly && config.CACertPath != "":
+		return nil, errors.New("config: ca_cert_path cannot be used with system_certs_only")
+	case config.CustomCertsOnly && config.CACertPath == "":
+		return nil, errors.New("config: custom_certs_only requires ca_cert_path")
+	case config.CACertPath == "":
+		return nil, nil
+	}
+
+	pem, err := os.ReadFile(config.CACertPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !config.CustomCertsOnly {
+		if pool, err = x509.SystemCertPool(); err != nil {
+			return nil, fmt.Errorf("failed to load system CA certificates: %w", err)
+		}
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", config.CACertPath)
+	}
+	return pool, nil
+}
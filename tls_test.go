@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is a throwaway certificate authority for TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(name string) (*testCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+// serverCert issues a certificate for 127.0.0.1 signed by the CA.
+func (ca *testCA) serverCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// newTLSServer starts a server whose certificate is signed by ca.
+func newTLSServer(t *testing.T, ca *testCA) *httptest.Server {
+	t.Helper()
+	cert, err := ca.serverCert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// systemCA stands in for a CA from the OS trust store. TestMain installs
+// it through SSL_CERT_FILE before anything loads the system roots.
+var systemCA *testCA
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	var err error
+	if systemCA, err = newTestCA("System Test CA"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	dir, err := os.MkdirTemp("", "cyberark-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	bundle := filepath.Join(dir, "system.pem")
+	if err := os.WriteFile(bundle, systemCA.pem, 0o600); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Setenv("SSL_CERT_FILE", bundle)
+	os.Setenv("SSL_CERT_DIR", dir)
+	return m.Run()
+}
+
+// writeCustomCA writes a new CA bundle to a temp file.
+func writeCustomCA(t *testing.T) (*testCA, string) {
+	t.Helper()
+	ca, err := newTestCA("Custom Test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, ca.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return ca, path
+}
+
+// canReach reports whether a client built from config accepts the
+// server's certificate.
+func canReach(t *testing.T, config Config, srv *httptest.Server) bool {
+	t.Helper()
+	config.BaseURL = srv.URL
+	config.Timeout = 5
+	client, err := NewAPIClient(&config)
+	if err != nil {
+		t.Fatalf("NewAPIClient: %v", err)
+	}
+	_, err = client.Get("")
+	return err == nil
+}
+
+func TestCertPoolTrustModes(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SSL_CERT_FILE only controls the system roots on Linux")
+	}
+	customCA, customPath := writeCustomCA(t)
+	systemSrv := newTLSServer(t, systemCA)
+	customSrv := newTLSServer(t, customCA)
+
+	tests := []struct {
+		name         string
+		config       Config
+		system, cust bool
+	}{
+		{"system roots", Config{}, true, false},
+		{"combined", Config{CACertPath: customPath}, true, true},
+		{"custom only", Config{CACertPath: customPath, CustomCertsOnly: true}, false, true},
+		{"system only", Config{SystemCertsOnly: true}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canReach(t, tt.config, systemSrv); got != tt.system {
+				t.Errorf("system-signed server trusted = %t, want %t", got, tt.system)
+			}
+			if got := canReach(t, tt.config, customSrv); got != tt.cust {
+				t.Errorf("custom-signed server trusted = %t, want %t", got, tt.cust)
+			}
+		})
+	}
+}
+
+func TestCertPoolErrors(t *testing.T) {
+	_, customPath := writeCustomCA(t)
+	notPEM := filepath.Join(t.TempDir(), "garbage.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"both toggles", Config{CACertPath: customPath, SystemCertsOnly: true, CustomCertsOnly: true}},
+		{"system only with bundle", Config{CACertPath: customPath, SystemCertsOnly: true}},
+		{"custom only without bundle", Config{CustomCertsOnly: true}},
+		{"missing bundle", Config{CACertPath: filepath.Join(t.TempDir(), "missing.pem")}},
+		{"invalid bundle", Config{CACertPath: notPEM}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := buildCertPool(&tt.config); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestCertWatchRecordsServerCertificate(t *testing.T) {
+	customCA, customPath := writeCustomCA(t)
+	srv := newTLSServer(t, customCA)
+	// The test certificate expires within the hour; leaving
+	// CertExpiryWarnDays at zero keeps the warning out of the test output.
+	client, err := NewAPIClient(&Config{BaseURL: srv.URL, CACertPath: customPath, Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.certs.certificate() != nil {
+		t.Fatal("certificate recorded before any request")
+	}
+	if _, err := client.Get(""); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	cert := client.certs.certificate()
+	if cert == nil {
+		t.Fatal("no certificate recorded after a TLS request")
+	}
+	if cert.Subject.CommonName != "127.0.0.1" {
+		t.Errorf("recorded certificate for %q, want the server's leaf", cert.Subject.CommonName)
+	}
+}
+
+func TestInsecureSkipVerifyAcceptsUntrustedServer(t *testing.T) {
+	untrusted, err := newTestCA("Untrusted Test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newTLSServer(t, untrusted)
+	if canReach(t, Config{}, srv) {
+		t.Fatal("untrusted server accepted without insecure_skip_verify")
+	}
+	if !canReach(t, Config{InsecureSkipVerify: true}, srv) {
+		t.Error("untrusted server rejected with insecure_skip_verify")
+	}
+}
+
+// writeClientCert issues a client certificate signed by ca and writes it
+// and its key as PEM files.
+func (ca *testCA) writeClientCert(t *testing.T) (certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "billing-app"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certPath, keyPath = filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func TestClientCertificateForMutualTLS(t *testing.T) {
+	customCA, customPath := writeCustomCA(t)
+	srv := newTLSServer(t, customCA)
+	pool := x509.NewCertPool()
+	pool.AddCert(customCA.cert)
+	srv.TLS.ClientCAs = pool
+	srv.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+
+	certPath, keyPath := customCA.writeClientCert(t)
+	if canReach(t, Config{CACertPath: customPath}, srv) {
+		t.Fatal("server requiring a client certificate accepted a client without one")
+	}
+	if !canReach(t, Config{CACertPath: customPath, ClientCertPath: certPath, ClientKeyPath: keyPath}, srv) {
+		t.Error("server rejected the configured client certificate")
+	}
+
+	if _, err := buildTLSConfig(&Config{ClientCertPath: certPath, ClientKeyPath: customPath}, &certWatch{}); err == nil {
+		t.Error("buildTLSConfig accepted a certificate paired with the wrong key file")
+	}
+	config := Config{BaseURL: "https://pvwa.corp.com", APISecret: "x", ClientCertPath: certPath}
+	if err := config.validate(); err == nil || !strings.Contains(err.Error(), "set together") {
+		t.Errorf("validate() with only client_cert_path = %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tokenCache keeps the session token from Logon in a file between runs,
+// so each invocation does not log on again; with RADIUS or other two-factor
+// logons that would mean a prompt every time.
+type tokenCache struct {
+	path string
+	ttl  time.Duration
+}
+
+// cachedSession is the content of the token cache file. A token is only
+// reused for the vault and user it was issued to.
+type cachedSession struct {
+	BaseURL  string    `json:"base_url"`
+	Username string    `json:"username"`
+	Token    string    `json:"token"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// defaultTokenCachePath returns ~/.cyberark_token, falling back to the
+// working directory when the home directory cannot be determined.
+func defaultTokenCachePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".cyberark_token"
+	}
+	return filepath.Join(home, ".cyberark_token")
+}
+
+// load returns the cached token for config's vault and user, if there is
+// one younger than the TTL; a zero TTL accepts a token of any age. A
+// missing, unreadable or mismatched cache just means logging on again.
+func (t *tokenCache) load(config *Config) (string, bool) {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return "", false
+	}
+	if info, err := os.Stat(t.path); err == nil {
+		if err := checkPrivate(t.path, info, "token cache"); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %v\n", err)
+			return "", false
+		}
+	}
+	var s cachedSession
+	if json.Unmarshal(data, &s) != nil || s.Token == "" {
+		return "", false
+	}
+	if s.BaseURL != config.BaseURL || s.Username != config.Username || (t.ttl > 0 && time.Since(s.IssuedAt) >= t.ttl) {
+		return "", false
+	}
+	return s.Token, true
+}
+
+// save writes the token for config's vault and user. The file is written
+// next to the old one and renamed over it, so a concurrent run never reads
+// half a token.
+func (t *tokenCache) save(config *Config, token string) error {
+	data, err := json.Marshal(cachedSession{BaseURL: config.BaseURL, Username: config.Username, Token: token, IssuedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(t.path), ".cyberark_token-*")
+	if err != nil {
+		return fmt.Errorf("failed to write token cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), t.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write token cache: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the cache file, reporting whether there was one.
+func (t *tokenCache) remove() (bool, error) {
+	err := os.Remove(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set at build time with
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// buildVersion returns the version and commit. Values not set with
+// -ldflags are taken from the module and VCS information the Go toolchain
+// embeds, when there is any; it records no build date.
+func buildVersion() (v, rev string) {
+	v, rev = version, commit
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v, rev
+	}
+	if v == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		v = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && rev == "unknown" {
+			rev = s.Value
+		}
+	}
+	return v, rev
+}
+
+// versionString is what version and --version print.
+func versionString() string {
+	v, rev := buildVersion()
+	return fmt.Sprintf("cyberark %s\ncommit:     %s\nbuilt:      %s\ngo version: %s %s/%s", v, rev, buildDate, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// VersionWorkflow prints the build metadata, for bug reports.
+type VersionWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("version", &VersionWorkflow{})
+}
+
+// configless implements configless: the version is needed most when the
+// configuration is the problem.
+func (w *VersionWorkflow) configless() {}
+
+// Synopsis implements DescribableWorkflow.
+func (w *VersionWorkflow) Synopsis() string {
+	return "print the version and build information"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *VersionWorkflow) FlagSpecs() []FlagSpec { return nil }
+
+// Execute implements Workflow.
+func (w *VersionWorkflow) Execute(client *APIClient, args []string) error {
+	fs := newFlagSet("version", "")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	fmt.Println(versionString())
+	return nil
+}
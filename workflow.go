@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Workflow is a named operation that can be run from the command line.
+// Each workflow parses its own flags from args.
+type Workflow interface {
+	Execute(client *APIClient, args []string) error
+}
+
+// DescribableWorkflow is implemented by workflows that describe
+// themselves, so that the usage message, --help and shell completion can
+// show them without running Execute. Every registered workflow implements
+// it; the flags of any other are found by running Execute with -help as a
+// last resort, see workflowFlags.
+type DescribableWorkflow interface {
+	Workflow
+	// Synopsis is a one-line description, shown next to the workflow's
+	// name in the usage message.
+	Synopsis() string
+	// FlagSpecs lists the flags Execute accepts.
+	FlagSpecs() []FlagSpec
+}
+
+// sessionless is implemented by workflows that handle the PVWA session
+// themselves, or need none, so run does not log on for them.
+type sessionless interface {
+	sessionless()
+}
+
+// configless is implemented by workflows that need neither a configuration
+// nor a client, so run does not load one for them and passes a nil client.
+type configless interface {
+	configless()
+}
+
+// configWriter is implemented by configless workflows that write the
+// config file, so run tells them the --config path.
+type configWriter interface {
+	configless
+	setConfigPath(path string)
+}
+
+// WorkflowRegistry maps workflow names to their implementations. Workflows
+// add themselves from init functions via RegisterWorkflow.
+var WorkflowRegistry = map[string]Workflow{}
+
+// RegisterWorkflow adds wf to the registry under name.
+func RegisterWorkflow(name string, wf Workflow) {
+	if _, exists := WorkflowRegistry[name]; exists {
+		panic(fmt.Sprintf("workflow %q registered twice", name))
+	}
+	WorkflowRegistry[name] = wf
+}
+
+// workflowNames returns the registered workflow names in sorted order.
+func workflowNames() []string {
+	names := make([]string, 0, len(WorkflowRegistry))
+	for name := range WorkflowRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newFlagSet returns a flag set for a workflow whose --help output shows
+// the usage line followed by the workflow's flags.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: cyberark %s %s\n\nOptions:\n", name, usage)
+		fs.PrintDefaults()
+	}
+	if flagSetProbe != nil {
+		flagSetProbe(fs)
+	}
+	return fs
+}
+
+// helpRequested reports whether args, a workflow's arguments, ask for its
+// help. Arguments after a -- terminator are not flags.
+func helpRequested(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--":
+			return false
+		case "-h", "-help", "--help":
+			return true
+		}
+	}
+	return false
+}
+
+// printWorkflowUsage prints the usage of the named workflow without
+// running it against a vault, so --help works before a config exists. A
+// DescribableWorkflow is printed from its description. Any other is run
+// with -help against an empty client and prints its own usage from the
+// flag set it parses.
+func printWorkflowUsage(name string, wf Workflow) (err error) {
+	d, ok := wf.(DescribableWorkflow)
+	if !ok {
+		defer func() {
+			if recover() != nil {
+				err = fmt.Errorf("workflow %q cannot describe its options without running", name)
+			}
+		}()
+		return wf.Execute(&APIClient{config: &Config{}}, []string{"-help"})
+	}
+
+	// FlagSpecs of the built-in workflows builds the flag set Execute
+	// parses, whose usage line names the required flags; print that when
+	// there is one.
+	var fs *flag.FlagSet
+	flagSetProbe = func(f *flag.FlagSet) {
+		if fs == nil {
+			fs = f
+		}
+	}
+	specs := d.FlagSpecs()
+	flagSetProbe = nil
+	if fs != nil {
+		fmt.Fprintf(os.Stderr, "%s\n\n", d.Synopsis())
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage: cyberark %s [options]\n\n%s\n", name, d.Synopsis())
+	if len(specs) == 0 {
+		return flag.ErrHelp
+	}
+	fmt.Fprintf(os.Stderr, "\nOptions:\n")
+	writeFlagSpecs(os.Stderr, specs)
+	return flag.ErrHelp
+}
+
+// writeFlagSpecs prints specs in the layout of flag.PrintDefaults.
+func writeFlagSpecs(w io.Writer, specs []FlagSpec) {
+	for _, s := range specs {
+		line := "  " + flagWord(s.Name)
+		if s.ValueName != "" {
+			line += " " + s.ValueName
+		}
+		usage := strings.ReplaceAll(s.Usage, "\n", "\n    \t")
+		if s.Default != "" {
+			usage += fmt.Sprintf(" (default %s)", s.Default)
+		}
+		fmt.Fprintf(w, "%s\n    \t%s\n", line, usage)
+	}
+}
+
+// isFlagSet reports whether the named flag was given on the command line.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,1748 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ListAccountsWorkflow lists the accounts visible to the caller.
+type ListAccountsWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("list-accounts", &ListAccountsWorkflow{})
+}
+
+// listAccountsFlags are the flags of list-accounts.
+type listAccountsFlags struct {
+	safe    string
+	limit   int
+	all     bool
+	reason  string
+	compact bool
+	output  outputOptions
+	checks  resultChecks
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *listAccountsFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("list-accounts", "[--safe NAME] [--limit N | --all] [--reason TEXT] [--output FORMAT | --compact] [--fail-if-empty|--fail-if-nonempty]")
+	fs.StringVar(&f.safe, "safe", "", "only list accounts in this safe")
+	fs.IntVar(&f.limit, "limit", 50, "maximum number of accounts to return (max_results still applies)")
+	fs.BoolVar(&f.all, "all", false, "page through every account instead of stopping at --limit (max_results still applies)")
+	fs.StringVar(&f.reason, "reason", "", "reason to send with the listing, for safes that require one")
+	fs.BoolVar(&f.compact, "compact", false, "print one short line per account")
+	f.output.registerFlags(fs)
+	f.checks.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ListAccountsWorkflow) Synopsis() string {
+	return "list the accounts visible to you"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ListAccountsWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(listAccountsFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ListAccountsWorkflow) Execute(client *APIClient, args []string) error {
+	f := listAccountsFlags{output: outputOptions{Format: client.outputFormat}}
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.compact && (isFlagSet(fs, "output") || isFlagSet(fs, "group-by")) {
+		return errors.New("--compact cannot be combined with --output or --group-by")
+	}
+	if f.all && isFlagSet(fs, "limit") {
+		return errors.New("--all and --limit cannot be combined")
+	}
+	if f.limit <= 0 {
+		return errors.New("--limit must be positive")
+	}
+
+	columns := []column[Account]{
+		{"ID", func(a Account) string { return a.ID }},
+		{"NAME", func(a Account) string { return a.Name }},
+		{"USERNAME", func(a Account) string { return a.UserName }},
+		{"ADDRESS", func(a Account) string { return valueOr(a.Address, "-") }},
+		{"PLATFORM", func(a Account) string { return a.PlatformID }},
+		{"SAFE", func(a Account) string { return a.SafeName }},
+		{"TYPE", func(a Account) string { return a.SecretType }},
+	}
+	r, err := newAccountRenderer(f.output, f.compact, os.Stdout, columns)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	if f.safe != "" {
+		params = safeFilter(f.safe)
+	}
+	found, total, truncated := 0, 0, false
+	if f.all {
+		err = fetchAccountsWithReason(client, params, f.safe, f.reason, func(page []Account) error {
+			found += len(page)
+			return r.write(page)
+		})
+		total = found
+	} else {
+		var page listPage[Account]
+		if page, truncated, err = fetchAccountPage(client, params, f.limit, f.safe, f.reason); err == nil {
+			found, total = len(page.Value), page.Count
+			err = r.write(page.Value)
+		}
+	}
+	if ferr := r.finish(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list accounts: %w", err)
+	}
+	switch {
+	case found == 0:
+		fmt.Fprintln(os.Stderr, "No accounts found")
+	case truncated:
+		warnTruncated(found, total)
+	case total > found:
+		fmt.Fprintf(os.Stderr, "Showing %d of %d accounts; raise --limit or pass --all to see the rest\n", found, total)
+	}
+	return f.checks.check(found)
+}
+
+// GetAccountWorkflow shows the details of one account.
+type GetAccountWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("get-account", &GetAccountWorkflow{})
+}
+
+// getAccountFlags are the flags of get-account.
+type getAccountFlags struct {
+	id       string
+	extended bool
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *getAccountFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("get-account", "--id ID [--extended]")
+	fs.StringVar(&f.id, "id", "", "account ID (required)")
+	fs.BoolVar(&f.extended, "extended", false, "also show CPM state and remote machines")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *GetAccountWorkflow) Synopsis() string {
+	return "show the details of one account"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *GetAccountWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(getAccountFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *GetAccountWorkflow) Execute(client *APIClient, args []string) error {
+	var f getAccountFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" {
+		fs.Usage()
+		return errors.New("--id is required")
+	}
+
+	a, err := getAccount(client, f.id)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("account %s not found", f.id)
+		}
+		return fmt.Errorf("failed to get account %s: %w", f.id, err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", a.ID)
+	fmt.Fprintf(tw, "Name:\t%s\n", a.Name)
+	fmt.Fprintf(tw, "Safe:\t%s\n", a.SafeName)
+	fmt.Fprintf(tw, "Platform:\t%s\n", a.PlatformID)
+	fmt.Fprintf(tw, "Address:\t%s\n", a.Address)
+	fmt.Fprintf(tw, "Username:\t%s\n", a.UserName)
+	fmt.Fprintf(tw, "Secret type:\t%s\n", a.SecretType)
+	if a.CreatedTime != 0 {
+		fmt.Fprintf(tw, "Created:\t%s\n", formatEpoch(a.CreatedTime))
+	}
+	fmt.Fprintf(tw, "Last used:\t%s\n", formatLastUsed(*a))
+	// Platform properties such as Port or LogonDomain are part of the
+	// account, so they are always shown.
+	keys := make([]string, 0, len(a.PlatformAccountProperties))
+	for k := range a.PlatformAccountProperties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%s:\t%v\n", k, a.PlatformAccountProperties[k])
+	}
+	if f.extended {
+		sm := a.SecretManagement
+		fmt.Fprintf(tw, "Automatic management:\t%t\n", sm.AutomaticManagementEnabled)
+		if sm.ManualManagementReason != "" {
+			fmt.Fprintf(tw, "Manual management reason:\t%s\n", sm.ManualManagementReason)
+		}
+		fmt.Fprintf(tw, "CPM status:\t%s\n", valueOr(sm.Status, "-"))
+		fmt.Fprintf(tw, "Last modified:\t%s\n", formatEpoch(sm.LastModifiedTime))
+		fmt.Fprintf(tw, "Last verified:\t%s\n", formatEpoch(sm.LastVerifiedTime))
+		fmt.Fprintf(tw, "Last reconciled:\t%s\n", formatEpoch(sm.LastReconciledTime))
+		if rm := a.RemoteMachinesAccess; rm != nil && rm.RemoteMachines != "" {
+			fmt.Fprintf(tw, "Remote machines:\t%s\n", strings.ReplaceAll(rm.RemoteMachines, ";", ", "))
+			fmt.Fprintf(tw, "Restricted to remote machines:\t%t\n", rm.AccessRestrictedToRemoteMachines)
+		}
+	}
+	return tw.Flush()
+}
+
+// GetPasswordWorkflow retrieves an account's secret for use in scripts.
+type GetPasswordWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("get-password", &GetPasswordWorkflow{})
+}
+
+// getPasswordFlags are the flags of get-password.
+type getPasswordFlags struct {
+	id  string
+	req retrieveRequest
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *getPasswordFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("get-password", "--id ID [--reason TEXT] [--ticket-id ID --ticket-system NAME]")
+	fs.StringVar(&f.id, "id", "", "account ID (required)")
+	fs.StringVar(&f.req.Reason, "reason", "", "reason for the retrieval, recorded in the vault's audit")
+	fs.StringVar(&f.req.TicketID, "ticket-id", "", "ticket authorizing the retrieval, where the platform requires one")
+	fs.StringVar(&f.req.TicketingSystemName, "ticket-system", "", "ticketing system the --ticket-id is checked against")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *GetPasswordWorkflow) Synopsis() string {
+	return "print an account's secret"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *GetPasswordWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(getPasswordFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *GetPasswordWorkflow) Execute(client *APIClient, args []string) error {
+	var f getPasswordFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" {
+		fs.Usage()
+		return errors.New("--id is required")
+	}
+
+	// Only the secret goes to stdout, so $(cyberark get-password ...)
+	// captures exactly the secret. It is never passed to an error or log
+	// message, and the audit log does not record bodies.
+	secret, err := retrieveSecret(client, f.id, f.req)
+	// As when listing accounts, a reason the vault asks for is prompted
+	// for at a terminal and the retrieval repeated once. A ticket is left
+	// to the flags, since it usually comes from another system.
+	if missing := missingRetrievalFields(err, f.req); len(missing) == 1 && missing[0] == "--reason" && isTerminal(os.Stdin) {
+		if f.req.Reason, err = ask(fmt.Sprintf("Reason for retrieving the secret of account %s: ", f.id)); err != nil {
+			return err
+		}
+		if f.req.Reason == "" {
+			return errors.New("no reason given")
+		}
+		secret, err = retrieveSecret(client, f.id, f.req)
+	}
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("account %s not found", f.id)
+		}
+		if missing := missingRetrievalFields(err, f.req); len(missing) > 0 {
+			return fmt.Errorf("retrieving the secret of account %s requires %s: %w", f.id, strings.Join(missing, " and "), err)
+		}
+		return fmt.Errorf("failed to retrieve the secret of account %s: %w", f.id, err)
+	}
+	fmt.Println(secret)
+	return nil
+}
+
+// accountStatusFilters maps --status values to a predicate on the CPM
+// status of an account. The API cannot filter on secretManagement.status,
+// so filtering happens client-side.
+var accountStatusFilters = map[string]func(SecretManagement) bool{
+	"failed":  func(s SecretManagement) bool { return s.Status == "failure" },
+	"success": func(s SecretManagement) bool { return s.Status == "success" },
+	"pending": func(s SecretManagement) bool { return s.Status != "failure" && s.Status != "success" },
+}
+
+// errSearchLimit stops paging once a search has found --limit matches.
+var errSearchLimit = errors.New("search limit reached")
+
+// SearchAccountsWorkflow searches accounts by keyword, safe and CPM status.
+// It is registered as both search-accounts and search.
+type SearchAccountsWorkflow struct {
+	name string
+}
+
+func init() {
+	RegisterWorkflow("search-accounts", &SearchAccountsWorkflow{name: "search-accounts"})
+	RegisterWorkflow("search", &SearchAccountsWorkflow{name: "search"})
+}
+
+// searchAccountsFlags are the flags of search-accounts and search.
+type searchAccountsFlags struct {
+	query      string
+	searchType string
+	safe       string
+	limit      int
+	all        bool
+	status     string
+	olderThan  ageFlag
+	compact    bool
+	reason     string
+	output     outputOptions
+	checks     resultChecks
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *searchAccountsFlags) flagSet(name string) *flag.FlagSet {
+	fs := newFlagSet(name, "[--query TEXT] [--search-type contains|startswith] [--safe NAME] [--status failed|success|pending] [--older-than AGE] [--limit N | --all] [--output FORMAT] [--stream] [--group-by COLUMN] [--compact] [--fail-if-empty|--fail-if-nonempty]")
+	fs.StringVar(&f.query, "query", "", "keywords to search for in account properties, e.g. part of a username or address")
+	fs.StringVar(&f.searchType, "search-type", "contains", "how --query is matched: contains or startswith")
+	fs.StringVar(&f.safe, "safe", "", "only search accounts in this safe")
+	fs.IntVar(&f.limit, "limit", 0, "stop after this many matches")
+	fs.BoolVar(&f.all, "all", false, "page through every match (the default without --limit)")
+	fs.StringVar(&f.status, "status", "", "only show accounts whose last CPM operation is failed, success or pending")
+	fs.Var(&f.olderThan, "older-than", "only show accounts not used within this age, e.g. 90d; never-used accounts always match")
+	fs.BoolVar(&f.compact, "compact", false, "print one short line per account")
+	fs.StringVar(&f.reason, "reason", "", "reason to send with the listing, for safes that require one")
+	f.output.registerFlags(fs)
+	f.checks.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *SearchAccountsWorkflow) Synopsis() string {
+	return "find accounts by keyword, safe and CPM status"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *SearchAccountsWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(searchAccountsFlags).flagSet(w.name))
+}
+
+// Execute implements Workflow.
+func (w *SearchAccountsWorkflow) Execute(client *APIClient, args []string) error {
+	f := searchAccountsFlags{output: outputOptions{Format: client.outputFormat}}
+	fs := f.flagSet(w.name)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.compact && (isFlagSet(fs, "output") || isFlagSet(fs, "group-by")) {
+		return errors.New("--compact cannot be combined with --output or --group-by")
+	}
+	if f.all && isFlagSet(fs, "limit") {
+		return errors.New("--all and --limit cannot be combined")
+	}
+	if isFlagSet(fs, "limit") && f.limit <= 0 {
+		return errors.New("--limit must be positive")
+	}
+	if f.searchType != "contains" && f.searchType != "startswith" {
+		return fmt.Errorf("invalid --search-type %q: must be contains or startswith", f.searchType)
+	}
+
+	var keeps []func(Account) bool
+	if f.status != "" {
+		statusOK, ok := accountStatusFilters[f.status]
+		if !ok {
+			return fmt.Errorf("invalid --status %q: must be failed, success or pending", f.status)
+		}
+		keeps = append(keeps, func(a Account) bool { return statusOK(a.SecretManagement) })
+	}
+	stale := isFlagSet(fs, "older-than")
+	if stale {
+		cutoff := time.Now().Add(-time.Duration(f.olderThan))
+		keeps = append(keeps, func(a Account) bool { return !a.usedSince(cutoff) })
+	}
+
+	params := url.Values{}
+	if f.safe != "" {
+		params = safeFilter(f.safe)
+	}
+	if f.query != "" {
+		params.Set("search", f.query)
+		params.Set("searchType", f.searchType)
+	}
+
+	// search pages through the matches, handing each page's share of
+	// --limit to fn and stopping once the limit is reached.
+	found, limited := 0, false
+	search := func(fn func([]Account) error) error {
+		err := fetchAccountsWithReason(client, params, f.safe, f.reason, func(page []Account) error {
+			matches := filterAccounts(page, keeps)
+			if f.limit > 0 {
+				matches = matches[:min(len(matches), f.limit-found)]
+			}
+			if len(matches) > 0 {
+				found += len(matches)
+				if err := fn(matches); err != nil {
+					return err
+				}
+			}
+			if f.limit > 0 && found >= f.limit {
+				return errSearchLimit
+			}
+			return nil
+		})
+		if errors.Is(err, errSearchLimit) {
+			limited, err = true, nil
+		}
+		return err
+	}
+
+	columns := []column[Account]{
+		{"ID", func(a Account) string { return a.ID }},
+		{"SAFE", func(a Account) string { return a.SafeName }},
+		{"USERNAME", func(a Account) string { return a.UserName }},
+		{"ADDRESS", func(a Account) string { return a.Address }},
+		{"PLATFORM", func(a Account) string { return a.PlatformID }},
+		{"STATUS", func(a Account) string { return valueOr(a.SecretManagement.Status, "-") }},
+	}
+	// Failed accounts drive remediation, so show when the CPM last
+	// succeeded and why the account is not managed, if it is not.
+	if f.status == "failed" {
+		columns = append(columns,
+			column[Account]{"LAST RECONCILED", func(a Account) string { return formatEpoch(a.SecretManagement.LastReconciledTime) }},
+			column[Account]{"LAST VERIFIED", func(a Account) string { return formatEpoch(a.SecretManagement.LastVerifiedTime) }},
+			column[Account]{"REASON", func(a Account) string { return valueOr(a.SecretManagement.ManualManagementReason, "-") }},
+		)
+	}
+	if stale {
+		columns = append(columns, column[Account]{"LAST USED", formatLastUsed})
+	}
+	r, err := newAccountRenderer(f.output, f.compact, os.Stdout, columns)
+	if err != nil {
+		return err
+	}
+	err = search(r.write)
+	if ferr := r.finish(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to search accounts: %w", err)
+	}
+	if found == 0 {
+		fmt.Fprintln(os.Stderr, noMatchesMessage(f.query, f.searchType))
+	}
+	printSearchLimited(limited, found)
+	return f.checks.check(found)
+}
+
+// noMatchesMessage is printed in place of an empty search result.
+func noMatchesMessage(query, searchType string) string {
+	switch {
+	case query == "":
+		return "No matching accounts found"
+	case searchType == "startswith":
+		return fmt.Sprintf("No accounts match %q; try fewer keywords or --search-type contains", query)
+	}
+	return fmt.Sprintf("No accounts match %q; try fewer or shorter keywords", query)
+}
+
+// printSearchLimited notes when a search stopped at --limit, as
+// list-accounts does when it stops short.
+func printSearchLimited(limited bool, found int) {
+	if limited {
+		fmt.Fprintf(os.Stderr, "Showing the first %d matches; raise --limit or pass --all to see any more\n", found)
+	}
+}
+
+// filterAccounts returns the accounts that pass every one of keeps.
+func filterAccounts(accounts []Account, keeps []func(Account) bool) []Account {
+	if len(keeps) == 0 {
+		return accounts
+	}
+	var out []Account
+	for _, a := range accounts {
+		matches := true
+		for _, keep := range keeps {
+			matches = matches && keep(a)
+		}
+		if matches {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// valueOr returns s, or fallback when s is empty.
+func valueOr(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// createAccountRequest is the body of POST /Accounts.
+type createAccountRequest struct {
+	Name                      string                `json:"name,omitempty"`
+	Address                   string                `json:"address"`
+	UserName                  string                `json:"userName"`
+	PlatformID                string                `json:"platformId"`
+	SafeName                  string                `json:"safeName"`
+	SecretType                string                `json:"secretType,omitempty"`
+	Secret                    string                `json:"secret,omitempty"`
+	PlatformAccountProperties map[string]string     `json:"platformAccountProperties,omitempty"`
+	SecretManagement          *SecretManagement     `json:"secretManagement,omitempty"`
+	RemoteMachinesAccess      *RemoteMachinesAccess `json:"remoteMachinesAccess,omitempty"`
+}
+
+// CreateAccountWorkflow onboards a new account. It is registered as both
+// create-account and add-account.
+type CreateAccountWorkflow struct {
+	name string
+}
+
+func init() {
+	RegisterWorkflow("create-account", &CreateAccountWorkflow{name: "create-account"})
+	RegisterWorkflow("add-account", &CreateAccountWorkflow{name: "add-account"})
+}
+
+// createAccountFlags are the flags of create-account and add-account.
+type createAccountFlags struct {
+	safe           string
+	platform       string
+	detect         bool
+	systemType     string
+	address        string
+	username       string
+	name           string
+	secretType     string
+	secret         string
+	manualReason   string
+	remoteMachines string
+	restricted     bool
+	properties     keyValueFlag
+	strict         bool
+	changeOnAdd    bool
+	wait           bool
+	pollOpts       pollOptions
+	preview        bodyPreview
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *createAccountFlags) flagSet(name string) *flag.FlagSet {
+	fs := newFlagSet(name, "--safe NAME (--platform ID | --detect-platform --system-type TYPE) --address HOST --username USER [options]")
+	fs.StringVar(&f.safe, "safe", "", "safe to store the account in (required)")
+	fs.StringVar(&f.platform, "platform", "", "platform ID (required unless --detect-platform is given)")
+	fs.BoolVar(&f.detect, "detect-platform", false, "choose the platform for --system-type from platform_map in the config")
+	fs.StringVar(&f.systemType, "system-type", "", "the device's system type or OS, as recorded in the CMDB")
+	fs.StringVar(&f.address, "address", "", "target address (required)")
+	fs.StringVar(&f.username, "username", "", "account user name (required)")
+	fs.StringVar(&f.name, "name", "", "account object name (default: generated by the vault)")
+	fs.StringVar(&f.secretType, "secret-type", "password", "secret type: password or key")
+	fs.StringVar(&f.secret, "secret", "", "initial secret value, or - to read it from stdin (prompted for without echo at a terminal when omitted)")
+	fs.StringVar(&f.manualReason, "manual-reason", "", "disable automatic CPM management with this reason")
+	fs.StringVar(&f.remoteMachines, "remote-machines", "", "comma-separated machines PSM for SSH users may connect to")
+	fs.BoolVar(&f.restricted, "access-restricted-to-remote-machines", false, "only allow connections to --remote-machines")
+	f.properties = keyValueFlag{}
+	fs.Var(f.properties, "properties", "platform properties as key=value pairs, comma-separated or repeated")
+	fs.BoolVar(&f.strict, "strict", false, "check --properties against the platform's definition before creating the account")
+	fs.BoolVar(&f.changeOnAdd, "change-on-add", false, "have the CPM replace the secret with a new random one right after onboarding")
+	fs.BoolVar(&f.wait, "wait", false, "with --change-on-add, wait for the CPM to finish the change")
+	f.pollOpts.registerFlags(fs)
+	f.preview.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *CreateAccountWorkflow) Synopsis() string {
+	return "onboard a new account"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *CreateAccountWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(createAccountFlags).flagSet(w.name))
+}
+
+// Execute implements Workflow.
+func (w *CreateAccountWorkflow) Execute(client *APIClient, args []string) error {
+	var f createAccountFlags
+	fs := f.flagSet(w.name)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.detect {
+		if f.platform != "" {
+			return errors.New("--platform and --detect-platform cannot be combined")
+		}
+		if f.systemType == "" {
+			return errors.New("--detect-platform requires --system-type")
+		}
+		detected, err := detectPlatform(f.systemType, client.config.PlatformMap)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Using platform %s for system type %s\n", detected, f.systemType)
+		f.platform = detected
+	} else if f.systemType != "" {
+		return errors.New("--system-type is only used with --detect-platform")
+	}
+	if f.safe == "" || f.platform == "" || f.address == "" || f.username == "" {
+		fs.Usage()
+		return errors.New("--safe, --platform, --address and --username are required")
+	}
+	if f.strict {
+		p, err := getPlatform(client, f.platform)
+		if err != nil {
+			return err
+		}
+		if err := checkAccountProperties(p, f.properties); err != nil {
+			return err
+		}
+	}
+	if f.changeOnAdd && f.manualReason != "" {
+		return errors.New("--change-on-add needs automatic management and cannot be combined with --manual-reason")
+	}
+	if f.wait && !f.changeOnAdd {
+		return errors.New("--wait requires --change-on-add")
+	}
+	if f.wait {
+		if err := f.pollOpts.validate(); err != nil {
+			return err
+		}
+	}
+	need := safeNeed{f.safe, []string{"addAccounts"}}
+	if f.changeOnAdd {
+		need.Permissions = append(need.Permissions, "initiateCPMAccountManagementOperations")
+	}
+	if err := client.precheck(need); err != nil {
+		return err
+	}
+	// With --change-on-add the CPM sets the secret, so there is nothing to
+	// ask for. An empty answer creates the account without one.
+	if f.secret == "" && !f.changeOnAdd && !f.preview.sendsNothing(client) && isTerminal(os.Stdin) {
+		answer, err := askSecret(fmt.Sprintf("Secret for %s@%s (empty for none): ", f.username, f.address))
+		if err != nil {
+			return err
+		}
+		f.secret = answer
+	} else {
+		answer, err := secretFlag(f.secret, fmt.Sprintf("Secret for %s@%s: ", f.username, f.address))
+		if err != nil {
+			return err
+		}
+		f.secret = answer
+	}
+
+	body := createAccountRequest{
+		Name:                      f.name,
+		Address:                   f.address,
+		UserName:                  f.username,
+		PlatformID:                f.platform,
+		SafeName:                  f.safe,
+		SecretType:                f.secretType,
+		Secret:                    f.secret,
+		PlatformAccountProperties: f.properties,
+	}
+	if f.manualReason != "" {
+		body.SecretManagement = &SecretManagement{ManualManagementReason: f.manualReason}
+	}
+	if f.changeOnAdd {
+		body.SecretManagement = &SecretManagement{AutomaticManagementEnabled: true}
+	}
+	if f.remoteMachines != "" {
+		machines, err := parseRemoteMachines(f.remoteMachines)
+		if err != nil {
+			return err
+		}
+		body.RemoteMachinesAccess = &RemoteMachinesAccess{
+			RemoteMachines:                   machines,
+			AccessRestrictedToRemoteMachines: f.restricted,
+		}
+	} else if f.restricted {
+		return errors.New("--access-restricted-to-remote-machines requires --remote-machines")
+	}
+
+	const endpoint = "PasswordVault/API/Accounts"
+	if send, err := f.preview.show(client, http.MethodPost, endpoint, body); !send || err != nil {
+		return err
+	}
+	data, err := client.Post(endpoint, body)
+	if err != nil {
+		if apiStatus(err) == http.StatusConflict {
+			return fmt.Errorf("an account for %s on %s already exists in safe %s; use apply-account or update-account to change it", f.username, f.address, f.safe)
+		}
+		return fmt.Errorf("failed to create account: %w", err)
+	}
+	createdID, err := extractField(data, "id")
+	if err != nil {
+		return fmt.Errorf("account created but the response could not be parsed: %w", err)
+	}
+	fmt.Println(createdID)
+	if !f.changeOnAdd {
+		return nil
+	}
+
+	// The vault has no onboarding option for this, so queue a change for
+	// the CPM as soon as the account exists.
+	started := time.Now()
+	changeEndpoint := "PasswordVault/API/Accounts/" + url.PathEscape(createdID) + "/Change"
+	if _, err := client.Post(changeEndpoint, map[string]bool{"ChangeEntireGroup": false}); err != nil {
+		return fmt.Errorf("account %s created, but queueing the initial change failed: %w", createdID, err)
+	}
+	fmt.Fprintf(os.Stderr, "Initial password change queued for account %s\n", createdID)
+	if !f.wait {
+		return nil
+	}
+	status, err := waitForCPM(client, createdID, started, f.pollOpts)
+	if err != nil {
+		return fmt.Errorf("account %s created, but the initial change did not succeed: %w", createdID, err)
+	}
+	fmt.Fprintf(os.Stderr, "Initial password change finished: %s\n", status)
+	return nil
+}
+
+// patchOp is one operation of a JSON Patch document.
+type patchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// accountPatchFields lists the account paths that can be changed with a
+// JSON Patch, and whether each one may also be removed.
+var accountPatchFields = map[string]struct{ removable bool }{
+	"/name":       {},
+	"/address":    {},
+	"/userName":   {},
+	"/platformId": {},
+	"/secretManagement/automaticManagementEnabled":           {},
+	"/secretManagement/manualManagementReason":               {removable: true},
+	"/remoteMachinesAccess/remoteMachines":                   {removable: true},
+	"/remoteMachinesAccess/accessRestrictedToRemoteMachines": {},
+}
+
+// accountPropertiesPath is the parent of the platform-specific properties,
+// which are addressed as /platformAccountProperties/<name>.
+const accountPropertiesPath = "/platformAccountProperties/"
+
+// patchPath returns a --set or --remove field as a JSON Pointer, so that
+// name=... means the same as /name=....
+func patchPath(field string) string {
+	if strings.HasPrefix(field, "/") {
+		return field
+	}
+	return "/" + field
+}
+
+// checkPatchPath returns an error unless path is an account field that can
+// be replaced, or removed when remove is set.
+func checkPatchPath(path string, remove bool) error {
+	if name, ok := strings.CutPrefix(path, accountPropertiesPath); ok {
+		if name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("invalid platform property path %q", path)
+		}
+		return nil
+	}
+	field, ok := accountPatchFields[path]
+	if !ok {
+		known := make([]string, 0, len(accountPatchFields))
+		for p := range accountPatchFields {
+			known = append(known, p)
+		}
+		sort.Strings(known)
+		return fmt.Errorf("unknown account field %q: must be %s or %s<name>", path, strings.Join(known, ", "), accountPropertiesPath)
+	}
+	if remove && !field.removable {
+		return fmt.Errorf("account field %q cannot be removed", path)
+	}
+	return nil
+}
+
+// patchValue converts a --set value to the type the API expects for path.
+func patchValue(path, value string) (interface{}, error) {
+	switch path {
+	case "/secretManagement/automaticManagementEnabled", "/remoteMachinesAccess/accessRestrictedToRemoteMachines":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", path, value)
+		}
+		return b, nil
+	case "/remoteMachinesAccess/remoteMachines":
+		return parseRemoteMachines(value)
+	}
+	return value, nil
+}
+
+// UpdateAccountWorkflow changes properties of an existing account.
+type UpdateAccountWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("update-account", &UpdateAccountWorkflow{})
+}
+
+// updateAccountFlags are the flags of update-account.
+type updateAccountFlags struct {
+	id             string
+	fields         map[string]*string
+	remoteMachines string
+	restricted     bool
+	sets, removes  stringsFlag
+	preview        bodyPreview
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *updateAccountFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("update-account", "--id ID [--name NAME] [--address HOST] [--username USER] [--platform ID] [--remote-machines HOSTS] [--set PATH=VALUE...] [--remove PATH...]")
+	fs.StringVar(&f.id, "id", "", "account ID (required)")
+	f.fields = map[string]*string{
+		"/name":       fs.String("name", "", "new account object name"),
+		"/address":    fs.String("address", "", "new target address"),
+		"/userName":   fs.String("username", "", "new user name"),
+		"/platformId": fs.String("platform", "", "new platform ID"),
+	}
+	fs.StringVar(&f.remoteMachines, "remote-machines", "", "comma-separated machines PSM for SSH users may connect to")
+	fs.BoolVar(&f.restricted, "access-restricted-to-remote-machines", false, "only allow connections to the remote machines")
+	fs.Var(&f.sets, "set", "replace a field, e.g. platformAccountProperties/Port=2222; the leading / is optional (repeatable)")
+	fs.Var(&f.removes, "remove", "remove a field, e.g. platformAccountProperties/Port (repeatable)")
+	f.preview.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *UpdateAccountWorkflow) Synopsis() string {
+	return "change the properties of an account"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *UpdateAccountWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(updateAccountFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *UpdateAccountWorkflow) Execute(client *APIClient, args []string) error {
+	var f updateAccountFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" {
+		fs.Usage()
+		return errors.New("--id is required")
+	}
+
+	var ops []patchOp
+	for _, path := range []string{"/name", "/address", "/userName", "/platformId"} {
+		if v := *f.fields[path]; v != "" {
+			ops = append(ops, patchOp{Op: "replace", Path: path, Value: v})
+		}
+	}
+	if f.remoteMachines != "" {
+		machines, err := parseRemoteMachines(f.remoteMachines)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, patchOp{Op: "replace", Path: "/remoteMachinesAccess/remoteMachines", Value: machines})
+	}
+	if isFlagSet(fs, "access-restricted-to-remote-machines") {
+		ops = append(ops, patchOp{Op: "replace", Path: "/remoteMachinesAccess/accessRestrictedToRemoteMachines", Value: f.restricted})
+	}
+	for _, set := range f.sets {
+		path, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return fmt.Errorf("invalid --set %q, expected PATH=VALUE", set)
+		}
+		path = patchPath(path)
+		if err := checkPatchPath(path, false); err != nil {
+			return err
+		}
+		v, err := patchValue(path, value)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, patchOp{Op: "replace", Path: path, Value: v})
+	}
+	for _, path := range f.removes {
+		path = patchPath(path)
+		if err := checkPatchPath(path, true); err != nil {
+			return err
+		}
+		ops = append(ops, patchOp{Op: "remove", Path: path})
+	}
+	if len(ops) == 0 {
+		return errors.New("nothing to update")
+	}
+
+	endpoint := "PasswordVault/API/Accounts/" + url.PathEscape(f.id)
+	if send, err := f.preview.show(client, http.MethodPatch, endpoint, ops); !send || err != nil {
+		return err
+	}
+	if _, err := client.Patch(endpoint, ops); err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("account %s not found", f.id)
+		}
+		// The vault answers 400 when the account's platform does not let
+		// one of the fields be changed, without saying which one.
+		if apiStatus(err) == http.StatusBadRequest {
+			paths := make([]string, len(ops))
+			for i, op := range ops {
+				paths[i] = op.Path
+			}
+			return fmt.Errorf("the vault refused to update account %s; one of %s cannot be changed on this account or its platform: %w",
+				f.id, strings.Join(paths, ", "), err)
+		}
+		return fmt.Errorf("failed to update account %s: %w", f.id, err)
+	}
+	fmt.Printf("Updated account %s\n", f.id)
+	return nil
+}
+
+// DeleteAccountWorkflow deletes an account.
+type DeleteAccountWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("delete-account", &DeleteAccountWorkflow{})
+}
+
+// deleteAccountFlags are the flags of delete-account.
+type deleteAccountFlags struct {
+	id            string
+	retainHistory bool
+	yes           bool
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *deleteAccountFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("delete-account", "--id ID [--retain-history] [--confirm]")
+	fs.StringVar(&f.id, "id", "", "account ID (required)")
+	fs.BoolVar(&f.retainHistory, "retain-history", false, "check the safe's retention policy first and refuse to delete unless it\n"+
+		"keeps password versions for a number of days; this is only a precheck, the\n"+
+		"delete request is the same and the vault applies whatever the policy says")
+	fs.BoolVar(&f.yes, "confirm", false, "skip the confirmation prompt")
+	fs.BoolVar(&f.yes, "yes", false, "same as --confirm")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *DeleteAccountWorkflow) Synopsis() string {
+	return "delete an account"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *DeleteAccountWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(deleteAccountFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *DeleteAccountWorkflow) Execute(client *APIClient, args []string) error {
+	var f deleteAccountFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" {
+		fs.Usage()
+		return errors.New("--id is required")
+	}
+
+	account, err := getAccount(client, f.id)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("account %s not found", f.id)
+		}
+		return fmt.Errorf("failed to get account %s: %w", f.id, err)
+	}
+	if err := client.precheck(safeNeed{account.SafeName, []string{"deleteAccounts"}}); err != nil {
+		return err
+	}
+	target := fmt.Sprintf("account %s (%s@%s in safe %s)", account.ID, account.UserName, account.Address, account.SafeName)
+
+	// The Accounts API has no per-request switch for this: a deleted
+	// account is kept, with its history, for as long as its safe's
+	// retention policy says. So check the policy rather than send an
+	// option the server would ignore.
+	if f.retainHistory {
+		safe, err := getSafe(client, account.SafeName)
+		if err != nil {
+			return fmt.Errorf("failed to read the retention policy of safe %s: %w", account.SafeName, err)
+		}
+		if safe.NumberOfDaysRetention == nil || *safe.NumberOfDaysRetention <= 0 {
+			policy := "no retention policy"
+			if r := safeRetention(*safe); r != "-" {
+				policy = "a retention of " + r
+			}
+			return fmt.Errorf("safe %s has %s rather than a number of days, so --retain-history cannot be honored; "+
+				"set --retention-days on the safe or delete without --retain-history", account.SafeName, policy)
+		}
+		fmt.Fprintf(os.Stderr, "Safe %s retains the deleted account's history for %d days\n", account.SafeName, *safe.NumberOfDaysRetention)
+	}
+
+	if !f.yes {
+		ok, err := confirm(fmt.Sprintf("Delete %s?", target))
+		if err != nil {
+			return fmt.Errorf("%w (pass --confirm to confirm)", err)
+		}
+		if !ok {
+			return errors.New("aborted")
+		}
+	}
+
+	if _, err := client.Delete("PasswordVault/API/Accounts/" + url.PathEscape(account.ID)); err != nil {
+		if apiStatus(err) == http.StatusForbidden {
+			return fmt.Errorf("not allowed to delete %s: the Delete accounts safe permission is required", target)
+		}
+		return fmt.Errorf("failed to delete %s: %w", target, err)
+	}
+	fmt.Printf("Deleted %s\n", target)
+	return nil
+}
+
+// BulkDeleteWorkflow deletes every account in a safe, or those of them
+// matching a search, for decommissioning a safe.
+type BulkDeleteWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("bulk-delete", &BulkDeleteWorkflow{})
+}
+
+// bulkDeleteFlags are the flags of bulk-delete.
+type bulkDeleteFlags struct {
+	safe         string
+	search       string
+	idsFile      string
+	dryRun       bool
+	yes          bool
+	failuresFile string
+	concurrency  int
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *bulkDeleteFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("bulk-delete", "(--safe NAME [--search TEXT] | --ids-file FILE) [--dry-run] [--confirm] [--failures FILE]")
+	fs.StringVar(&f.safe, "safe", "", "safe whose accounts to delete")
+	fs.StringVar(&f.search, "search", "", "only delete the safe's accounts matching these keywords")
+	fs.StringVar(&f.idsFile, "ids-file", "", "delete the account IDs in this file, one per line, such as the --failures file of an earlier run")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "list the accounts that would be deleted without deleting them")
+	fs.BoolVar(&f.yes, "confirm", false, "skip the confirmation prompt")
+	fs.BoolVar(&f.yes, "yes", false, "same as --confirm")
+	fs.StringVar(&f.failuresFile, "failures", "", "write the IDs of accounts that could not be deleted to this file, for a retry with --ids-file")
+	fs.IntVar(&f.concurrency, "concurrency", 5, "number of accounts to delete in parallel")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *BulkDeleteWorkflow) Synopsis() string {
+	return "delete a safe's accounts, or a list of accounts"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *BulkDeleteWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(bulkDeleteFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *BulkDeleteWorkflow) Execute(client *APIClient, args []string) error {
+	var f bulkDeleteFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (f.safe == "") == (f.idsFile == "") {
+		fs.Usage()
+		return errors.New("exactly one of --safe and --ids-file is required")
+	}
+	if f.search != "" && f.safe == "" {
+		return errors.New("--search requires --safe")
+	}
+
+	var accounts []Account
+	target := "safe " + f.safe
+	if f.idsFile != "" {
+		ids, err := readLines(f.idsFile)
+		if err != nil {
+			return fmt.Errorf("failed to read --ids-file: %w", err)
+		}
+		for _, id := range ids {
+			accounts = append(accounts, Account{ID: id})
+		}
+		target = f.idsFile
+	} else {
+		if !f.dryRun {
+			if err := client.precheck(safeNeed{f.safe, []string{"listAccounts", "deleteAccounts"}}); err != nil {
+				return err
+			}
+		}
+		params := safeFilter(f.safe)
+		if f.search != "" {
+			params.Set("search", f.search)
+		}
+		err := fetchAccounts(client, params, func(page []Account) error {
+			accounts = append(accounts, page...)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list accounts in safe %s: %w", f.safe, err)
+		}
+	}
+	if len(accounts) == 0 {
+		fmt.Fprintf(os.Stderr, "No accounts to delete in %s\n", target)
+		return nil
+	}
+
+	plural := "s"
+	if len(accounts) == 1 {
+		plural = ""
+	}
+	if f.dryRun {
+		for _, a := range accounts {
+			fmt.Printf("would delete  %s\n", bulkDeleteLabel(a))
+		}
+		fmt.Printf("\n[DRY RUN] %d account%s would be deleted from %s\n", len(accounts), plural, target)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%d account%s in %s will be deleted\n", len(accounts), plural, target)
+	if !f.yes {
+		ok, err := confirm(fmt.Sprintf("Delete %d account%s?", len(accounts), plural))
+		if err != nil {
+			return fmt.Errorf("%w (pass --confirm to confirm)", err)
+		}
+		if !ok {
+			return errors.New("aborted")
+		}
+	}
+
+	var mu sync.Mutex
+	report := func(a Account, outcome string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			fmt.Printf("FAILED   %s: %v\n", bulkDeleteLabel(a), err)
+			return
+		}
+		fmt.Printf("%-8s %s\n", outcome, bulkDeleteLabel(a))
+	}
+	ctx := client.baseContext()
+	errs := runConcurrent(ctx, accounts, f.concurrency, func(a Account) error {
+		_, err := client.Delete("PasswordVault/API/Accounts/" + url.PathEscape(a.ID))
+		outcome := "deleted"
+		switch {
+		case isNotFound(err):
+			// Someone else got there first, which is what was wanted.
+			outcome, err = "gone", nil
+		case apiStatus(err) == http.StatusForbidden:
+			err = errors.New("not allowed: the Delete accounts safe permission is required")
+		}
+		report(a, outcome, err)
+		return err
+	})
+
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, accounts[i].ID)
+		}
+	}
+	fmt.Printf("\n%d deleted, %d failed\n", len(accounts)-len(failed), len(failed))
+	if len(failed) == 0 {
+		return nil
+	}
+	if f.failuresFile != "" {
+		if err := os.WriteFile(f.failuresFile, []byte(strings.Join(failed, "\n")+"\n"), 0o600); err != nil {
+			return fmt.Errorf("failed to write --failures file: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "The IDs of the accounts not deleted are in %s; retry with --ids-file %s\n", f.failuresFile, f.failuresFile)
+	} else {
+		fmt.Fprintf(os.Stderr, "Not deleted: %s\n", strings.Join(failed, " "))
+	}
+	if err := context.Cause(ctx); err != nil {
+		return fmt.Errorf("stopped before every account was deleted: %w", err)
+	}
+	return fmt.Errorf("%d of %d accounts could not be deleted", len(failed), len(accounts))
+}
+
+// bulkDeleteLabel describes an account in bulk-delete's output. Accounts
+// read from --ids-file are only known by ID.
+func bulkDeleteLabel(a Account) string {
+	if a.SafeName == "" {
+		return a.ID
+	}
+	return fmt.Sprintf("%s  %s@%s", a.ID, a.UserName, a.Address)
+}
+
+// MoveAccountWorkflow copies an account to another safe and, with
+// --delete-source, deletes the original. The API has no move, so this is
+// a create followed by a delete, and the delete only happens once the
+// copy is known to exist.
+type MoveAccountWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("move-account", &MoveAccountWorkflow{})
+}
+
+// moveAccountFlags are the flags of move-account.
+type moveAccountFlags struct {
+	id           string
+	dest         string
+	withSecret   bool
+	reason       string
+	deleteSource bool
+	yes          bool
+	preview      bodyPreview
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *moveAccountFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("move-account", "--id ID --dest-safe NAME [--with-secret [--reason TEXT]] [--delete-source [--confirm]]")
+	fs.StringVar(&f.id, "id", "", "account ID (required)")
+	fs.StringVar(&f.dest, "dest-safe", "", "safe to create the account in (required)")
+	fs.BoolVar(&f.withSecret, "with-secret", false, "retrieve the secret and set it on the copy; needs the Retrieve accounts permission on the source safe")
+	fs.StringVar(&f.reason, "reason", "", "reason for retrieving the secret, where the safe requires one")
+	fs.BoolVar(&f.deleteSource, "delete-source", false, "delete the original once the copy exists, making this a move")
+	fs.BoolVar(&f.yes, "confirm", false, "skip the confirmation prompt before deleting the original")
+	fs.BoolVar(&f.yes, "yes", false, "same as --confirm")
+	f.preview.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *MoveAccountWorkflow) Synopsis() string {
+	return "copy an account to another safe, optionally deleting the original"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *MoveAccountWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(moveAccountFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *MoveAccountWorkflow) Execute(client *APIClient, args []string) error {
+	var f moveAccountFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" || f.dest == "" {
+		fs.Usage()
+		return errors.New("--id and --dest-safe are required")
+	}
+
+	src, err := getAccount(client, f.id)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("account %s not found", f.id)
+		}
+		return fmt.Errorf("failed to get account %s: %w", f.id, err)
+	}
+	if strings.EqualFold(src.SafeName, f.dest) {
+		return fmt.Errorf("account %s is already in safe %s", src.ID, src.SafeName)
+	}
+	needs := []safeNeed{{f.dest, []string{"addAccounts"}}}
+	if f.withSecret {
+		needs = append(needs, safeNeed{src.SafeName, []string{"retrieveAccounts"}})
+	}
+	if f.deleteSource {
+		needs = append(needs, safeNeed{src.SafeName, []string{"deleteAccounts"}})
+	}
+	if err := client.precheck(needs...); err != nil {
+		return err
+	}
+	target := fmt.Sprintf("%s@%s", src.UserName, src.Address)
+	existing, err := findAccount(client, f.dest, src.UserName, src.Address)
+	if err != nil {
+		return fmt.Errorf("failed to check safe %s for %s: %w", f.dest, target, err)
+	}
+	if existing != nil {
+		return fmt.Errorf("safe %s already has an account for %s (%s); nothing was copied", f.dest, target, existing.ID)
+	}
+
+	body := copiedAccountRequest(src, f.dest)
+	switch {
+	case !f.withSecret:
+		fmt.Fprintf(os.Stderr, "Warning: the copy is created without the secret; pass --with-secret to carry it over\n")
+	case f.preview.sendsNothing(client):
+		// Nothing is sent, so there is no need to read the secret either.
+	default:
+		req := retrieveRequest{Reason: f.reason}
+		secret, err := retrieveSecret(client, src.ID, req)
+		missing := missingRetrievalFields(err, req)
+		switch {
+		case len(missing) > 0:
+			return fmt.Errorf("retrieving the secret of account %s requires %s, so nothing was copied: %w", src.ID, strings.Join(missing, " and "), err)
+		case apiStatus(err) == http.StatusForbidden:
+			fmt.Fprintf(os.Stderr, "Warning: the secret cannot be carried over: retrieving it needs the Retrieve accounts permission on safe %s; the copy is created without it\n", src.SafeName)
+		case err != nil:
+			return fmt.Errorf("failed to retrieve the secret of account %s, so nothing was copied: %w", src.ID, err)
+		default:
+			body.Secret = secret
+		}
+	}
+
+	const endpoint = "PasswordVault/API/Accounts"
+	if send, err := f.preview.show(client, http.MethodPost, endpoint, body); !send || err != nil {
+		return err
+	}
+	data, err := client.Post(endpoint, body)
+	if err != nil {
+		return fmt.Errorf("failed to create %s in safe %s; account %s was left as it is: %w", target, f.dest, src.ID, err)
+	}
+	createdID, err := extractField(data, "id")
+	if err != nil {
+		return fmt.Errorf("created %s in safe %s, but the response could not be parsed; account %s was left as it is: %w", target, f.dest, src.ID, err)
+	}
+	// Under --dry-run the create was not sent, so there is no copy to read
+	// back.
+	if !client.dryRun {
+		copied, err := getAccount(client, createdID)
+		if err != nil || !strings.EqualFold(copied.SafeName, f.dest) {
+			if err == nil {
+				err = fmt.Errorf("it is in safe %s", copied.SafeName)
+			}
+			return fmt.Errorf("created account %s, but could not confirm it is in safe %s; account %s was left as it is: %w", createdID, f.dest, src.ID, err)
+		}
+	}
+	fmt.Printf("Copied account %s to safe %s as %s\n", src.ID, f.dest, createdID)
+	if !f.deleteSource {
+		return nil
+	}
+
+	if !f.yes {
+		ok, err := confirm(fmt.Sprintf("Delete the original, account %s in safe %s?", src.ID, src.SafeName))
+		if err != nil {
+			return fmt.Errorf("%w (pass --confirm to confirm); the copy %s was kept", err, createdID)
+		}
+		if !ok {
+			return fmt.Errorf("aborted; the copy %s was kept alongside account %s", createdID, src.ID)
+		}
+	}
+	if _, err := client.Delete("PasswordVault/API/Accounts/" + url.PathEscape(src.ID)); err != nil {
+		return fmt.Errorf("account %s was copied to %s, but deleting the original failed; delete it with delete-account --id %s: %w", src.ID, createdID, src.ID, err)
+	}
+	fmt.Printf("Deleted the original, account %s in safe %s\n", src.ID, src.SafeName)
+	return nil
+}
+
+// copiedAccountRequest returns the request that creates a copy of a in
+// safe, without its secret.
+func copiedAccountRequest(a *Account, safe string) createAccountRequest {
+	body := createAccountRequest{
+		Name:                 a.Name,
+		Address:              a.Address,
+		UserName:             a.UserName,
+		PlatformID:           a.PlatformID,
+		SafeName:             safe,
+		SecretType:           a.SecretType,
+		RemoteMachinesAccess: a.RemoteMachinesAccess,
+		SecretManagement: &SecretManagement{
+			AutomaticManagementEnabled: a.SecretManagement.AutomaticManagementEnabled,
+			ManualManagementReason:     a.SecretManagement.ManualManagementReason,
+		},
+	}
+	if len(a.PlatformAccountProperties) > 0 {
+		body.PlatformAccountProperties = make(map[string]string, len(a.PlatformAccountProperties))
+		for k, v := range a.PlatformAccountProperties {
+			body.PlatformAccountProperties[k] = fmt.Sprint(v)
+		}
+	}
+	return body
+}
+
+// retrieveRequest is the body of POST /Accounts/{id}/Password/Retrieve.
+// Platforms can require a reason, a ticket, or both, for every retrieval.
+type retrieveRequest struct {
+	Reason              string `json:"reason,omitempty"`
+	TicketingSystemName string `json:"TicketingSystemName,omitempty"`
+	TicketID            string `json:"TicketId,omitempty"`
+}
+
+// missingRetrievalFields returns the flags of the fields a refused
+// retrieval asked for that req leaves empty, such as --reason or
+// --ticket-id, for an error that names them.
+func missingRetrievalFields(err error, req retrieveRequest) []string {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusForbidden) {
+		return nil
+	}
+	body := bytes.ToLower(apiErr.Body)
+	var missing []string
+	if req.Reason == "" && bytes.Contains(body, []byte("reason")) {
+		missing = append(missing, "--reason")
+	}
+	if bytes.Contains(body, []byte("ticket")) {
+		if req.TicketID == "" {
+			missing = append(missing, "--ticket-id")
+		}
+		if req.TicketingSystemName == "" {
+			missing = append(missing, "--ticket-system")
+		}
+	}
+	return missing
+}
+
+// retrieveSecret returns the secret of an account. Callers must never
+// pass it to an error or log message.
+func retrieveSecret(client *APIClient, id string, req retrieveRequest) (string, error) {
+	data, err := client.Post("PasswordVault/API/Accounts/"+url.PathEscape(id)+"/Password/Retrieve", req)
+	if err != nil {
+		return "", err
+	}
+	var secret string
+	if err := json.Unmarshal(data, &secret); err != nil {
+		return "", errors.New("the response is not a JSON string")
+	}
+	return secret, nil
+}
+
+// ChangePasswordWorkflow has the CPM rotate an account's secret, to a
+// random value or to one given with --new-secret.
+type ChangePasswordWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("change-password", &ChangePasswordWorkflow{})
+}
+
+// changeRefusedByPlatform reports whether err is the vault refusing a
+// manually supplied password because the account's platform forbids it.
+func changeRefusedByPlatform(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusForbidden) {
+		return false
+	}
+	return bytes.Contains(bytes.ToLower(apiErr.Body), []byte("manual"))
+}
+
+// changePasswordFlags are the flags of change-password.
+type changePasswordFlags struct {
+	id        string
+	newSecret string
+	immediate bool
+	wait      bool
+	pollOpts  pollOptions
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *changePasswordFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("change-password", "--id ID [--new-secret VALUE [--immediate]] [--wait]")
+	fs.StringVar(&f.id, "id", "", "account ID (required)")
+	fs.StringVar(&f.newSecret, "new-secret", "", "have the CPM set this value instead of a random one; - reads it from stdin, without echo at a terminal")
+	fs.BoolVar(&f.immediate, "immediate", false, "with --new-secret, change now rather than at the next scheduled change")
+	fs.BoolVar(&f.wait, "wait", false, "wait for the CPM to finish the change")
+	f.pollOpts.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ChangePasswordWorkflow) Synopsis() string {
+	return "have the CPM rotate an account's secret"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ChangePasswordWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(changePasswordFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ChangePasswordWorkflow) Execute(client *APIClient, args []string) error {
+	var f changePasswordFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" {
+		fs.Usage()
+		return errors.New("--id is required")
+	}
+	// A change without a given value is always immediate.
+	if f.immediate && f.newSecret == "" {
+		return errors.New("--immediate requires --new-secret")
+	}
+	if f.wait && f.newSecret != "" && !f.immediate {
+		return errors.New("--wait requires --immediate when --new-secret is given")
+	}
+	if f.wait {
+		if err := f.pollOpts.validate(); err != nil {
+			return err
+		}
+	}
+
+	account, err := getAccount(client, f.id)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("account %s not found", f.id)
+		}
+		return fmt.Errorf("failed to get account %s: %w", f.id, err)
+	}
+	if err := client.precheck(safeNeed{account.SafeName, []string{"initiateCPMAccountManagementOperations"}}); err != nil {
+		return err
+	}
+	target := fmt.Sprintf("account %s (%s@%s)", account.ID, account.UserName, account.Address)
+	if f.newSecret, err = secretFlag(f.newSecret, fmt.Sprintf("New secret for %s: ", target)); err != nil {
+		return err
+	}
+
+	endpoint := "PasswordVault/API/Accounts/" + url.PathEscape(account.ID)
+	var body interface{}
+	if f.newSecret != "" {
+		endpoint += "/SetNextPassword"
+		body = map[string]interface{}{"ChangeImmediately": f.immediate, "NewCredentials": f.newSecret}
+	} else {
+		endpoint += "/Change"
+		body = map[string]bool{"ChangeEntireGroup": false}
+	}
+	started := time.Now()
+	if _, err := client.Post(endpoint, body); err != nil {
+		switch {
+		case f.newSecret != "" && changeRefusedByPlatform(err):
+			return fmt.Errorf("platform %s does not allow setting the password of %s manually; run change-password without --new-secret to let the CPM generate one", account.PlatformID, target)
+		case apiStatus(err) == http.StatusForbidden:
+			return fmt.Errorf("not allowed to change the password of %s: the Initiate CPM account management operations safe permission is required", target)
+		}
+		return fmt.Errorf("failed to change the password of %s: %w", target, err)
+	}
+
+	if f.newSecret != "" && !f.immediate {
+		fmt.Printf("New password for %s set for the next scheduled change\n", target)
+		return nil
+	}
+	fmt.Printf("Password change for %s queued for the CPM\n", target)
+	if !f.wait {
+		return nil
+	}
+	status, err := waitForCPM(client, account.ID, started, f.pollOpts)
+	if err != nil {
+		return fmt.Errorf("the password change for %s did not succeed: %w", target, err)
+	}
+	fmt.Printf("Password change finished: %s\n", status)
+	return nil
+}
+
+// VerifyCredentialWorkflow has the CPM check that an account's stored
+// secret still works against the target.
+type VerifyCredentialWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("verify-credential", &VerifyCredentialWorkflow{})
+}
+
+// cpmRequestRefused reports whether err is a 400 or 403 whose body
+// mentions topic and one of hints. The vault words these refusals
+// differently between versions, so they are matched loosely.
+func cpmRequestRefused(err error, topic string, hints ...string) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusForbidden) {
+		return false
+	}
+	body := bytes.ToLower(apiErr.Body)
+	if !bytes.Contains(body, []byte(topic)) {
+		return false
+	}
+	for _, hint := range hints {
+		if bytes.Contains(body, []byte(hint)) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyRefusedByPlatform reports whether err is the vault refusing a
+// verification because the account's platform does not allow one, as
+// opposed to the request itself failing.
+func verifyRefusedByPlatform(err error) bool {
+	return cpmRequestRefused(err, "verif", "not allowed", "not supported", "policy", "disabled")
+}
+
+// verifyCredentialFlags are the flags of verify-credential.
+type verifyCredentialFlags struct {
+	id       string
+	wait     bool
+	pollOpts pollOptions
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *verifyCredentialFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("verify-credential", "--id ID [--wait [--timeout DURATION]]")
+	fs.StringVar(&f.id, "id", "", "account ID (required)")
+	fs.BoolVar(&f.wait, "wait", false, "wait for the CPM to finish the verification and report the outcome")
+	f.pollOpts.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *VerifyCredentialWorkflow) Synopsis() string {
+	return "have the CPM check an account's secret"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *VerifyCredentialWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(verifyCredentialFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *VerifyCredentialWorkflow) Execute(client *APIClient, args []string) error {
+	var f verifyCredentialFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" {
+		fs.Usage()
+		return errors.New("--id is required")
+	}
+	if f.wait {
+		if err := f.pollOpts.validate(); err != nil {
+			return err
+		}
+	}
+
+	account, err := getAccount(client, f.id)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("account %s not found", f.id)
+		}
+		return fmt.Errorf("failed to get account %s: %w", f.id, err)
+	}
+	if err := client.precheck(safeNeed{account.SafeName, []string{"initiateCPMAccountManagementOperations"}}); err != nil {
+		return err
+	}
+	target := fmt.Sprintf("account %s (%s@%s)", account.ID, account.UserName, account.Address)
+
+	started := time.Now()
+	if _, err := client.Post("PasswordVault/API/Accounts/"+url.PathEscape(account.ID)+"/Verify", nil); err != nil {
+		switch {
+		case verifyRefusedByPlatform(err):
+			return fmt.Errorf("platform %s does not allow verifying %s; nothing was checked", account.PlatformID, target)
+		case apiStatus(err) == http.StatusForbidden:
+			return fmt.Errorf("not allowed to verify %s: the Initiate CPM account management operations safe permission is required", target)
+		}
+		return fmt.Errorf("failed to verify %s: %w", target, err)
+	}
+
+	fmt.Printf("Verification of %s queued for the CPM\n", target)
+	if !f.wait {
+		return nil
+	}
+	status, err := waitForCPM(client, account.ID, started, f.pollOpts)
+	if err != nil {
+		return fmt.Errorf("the stored secret of %s could not be verified: %w", target, err)
+	}
+	fmt.Printf("Verification finished: %s; the stored secret works\n", status)
+	return nil
+}
+
+// ReconcileWorkflow has the CPM reset an account's secret using the
+// reconcile account linked to it, for when the vault and the target have
+// drifted out of sync.
+type ReconcileWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("reconcile", &ReconcileWorkflow{})
+}
+
+// noReconcileAccount reports whether err is the vault refusing a
+// reconciliation because no reconcile account is linked to the account or
+// configured on its platform.
+func noReconcileAccount(err error) bool {
+	return cpmRequestRefused(err, "reconcil", "not defined", "not configured", "no reconcile", "missing", "not linked", "not associated")
+}
+
+// reconcileFlags are the flags of reconcile.
+type reconcileFlags struct {
+	id       string
+	wait     bool
+	pollOpts pollOptions
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *reconcileFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("reconcile", "--id ID [--wait [--timeout DURATION]]")
+	fs.StringVar(&f.id, "id", "", "account ID (required)")
+	fs.BoolVar(&f.wait, "wait", false, "wait for the CPM to finish the reconciliation and report the outcome")
+	f.pollOpts.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ReconcileWorkflow) Synopsis() string {
+	return "have the CPM reset an account's secret with its reconcile account"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ReconcileWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(reconcileFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ReconcileWorkflow) Execute(client *APIClient, args []string) error {
+	var f reconcileFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" {
+		fs.Usage()
+		return errors.New("--id is required")
+	}
+	if f.wait {
+		if err := f.pollOpts.validate(); err != nil {
+			return err
+		}
+	}
+
+	account, err := getAccount(client, f.id)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("account %s not found", f.id)
+		}
+		return fmt.Errorf("failed to get account %s: %w", f.id, err)
+	}
+	if err := client.precheck(safeNeed{account.SafeName, []string{"initiateCPMAccountManagementOperations"}}); err != nil {
+		return err
+	}
+	target := fmt.Sprintf("account %s (%s@%s)", account.ID, account.UserName, account.Address)
+
+	started := time.Now()
+	if _, err := client.Post("PasswordVault/API/Accounts/"+url.PathEscape(account.ID)+"/Reconcile", nil); err != nil {
+		switch {
+		case noReconcileAccount(err):
+			return fmt.Errorf("%s has no reconcile account: link one to the account, or set one on platform %s, before reconciling", target, account.PlatformID)
+		case apiStatus(err) == http.StatusForbidden:
+			return fmt.Errorf("not allowed to reconcile %s: the Initiate CPM account management operations safe permission is required", target)
+		}
+		return fmt.Errorf("failed to reconcile %s: %w", target, err)
+	}
+
+	fmt.Printf("Reconciliation of %s queued for the CPM\n", target)
+	if !f.wait {
+		return nil
+	}
+	status, err := waitForCPM(client, account.ID, started, f.pollOpts)
+	if err != nil {
+		return fmt.Errorf("the reconciliation of %s did not succeed: %w", target, err)
+	}
+	fmt.Printf("Reconciliation finished: %s\n", status)
+	return nil
+}
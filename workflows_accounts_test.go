@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCPMRefusalsAreToldApartFromFailures(t *testing.T) {
+	tests := []struct {
+		err         error
+		noReconcile bool
+		noVerify    bool
+	}{
+		{newAPIError(http.StatusBadRequest, []byte(`{"ErrorCode":"ITATS534E","ErrorMessage":"Reconcile account is not defined for this account"}`)), true, false},
+		{newAPIError(http.StatusForbidden, []byte(`{"ErrorCode":"PASWS170E","ErrorMessage":"Verification is not allowed according to the platform policy"}`)), false, true},
+		{newAPIError(http.StatusBadRequest, []byte(`{"ErrorCode":"PASWS011E","ErrorMessage":"Reconcile failed"}`)), false, false},
+		{newAPIError(http.StatusInternalServerError, []byte(`{"ErrorMessage":"Reconcile account is not defined"}`)), false, false},
+		{errors.New("connection refused"), false, false},
+	}
+	for _, tt := range tests {
+		if got := noReconcileAccount(tt.err); got != tt.noReconcile {
+			t.Errorf("noReconcileAccount(%v) = %t, want %t", tt.err, got, tt.noReconcile)
+		}
+		if got := verifyRefusedByPlatform(tt.err); got != tt.noVerify {
+			t.Errorf("verifyRefusedByPlatform(%v) = %t, want %t", tt.err, got, tt.noVerify)
+		}
+	}
+}
+
+func TestListAccountsSendsReasonAndHonorsMaxResults(t *testing.T) {
+	var limits []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("reason") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"ErrorCode":"PASWS013E","ErrorMessage":"You must specify a reason"}`))
+			return
+		}
+		limits = append(limits, r.URL.Query().Get("limit"))
+		w.Write([]byte(`{"value":[{"id":"3_1"},{"id":"3_2"}],"count":40}`))
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	if err := (&ListAccountsWorkflow{}).Execute(client, []string{"--safe", "Hardened"}); err == nil || !strings.Contains(err.Error(), "--reason") {
+		t.Errorf("list-accounts without a reason = %v, want a hint to pass --reason", err)
+	}
+	client.config.MaxResults = 10
+	if err := (&ListAccountsWorkflow{}).Execute(client, []string{"--safe", "Hardened", "--reason", "audit"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&ListAccountsWorkflow{}).Execute(client, []string{"--safe", "Hardened", "--reason", "audit", "--limit", "5"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(limits, " ") != "10 5" {
+		t.Errorf("limits sent = %v, want max_results to cap --limit", limits)
+	}
+}
+
+func TestListAccountsFailIfNonempty(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"value":[{"id":"3_1"}],"count":1}`))
+	}))
+	defer srv.Close()
+
+	var exit *exitError
+	err := (&ListAccountsWorkflow{}).Execute(newTestClient(t, srv), []string{"--fail-if-nonempty"})
+	if !errors.As(err, &exit) || exit.code != exitNonEmpty {
+		t.Errorf("list-accounts --fail-if-nonempty = %v, want exit status %d", err, exitNonEmpty)
+	}
+}
+
+func TestCompactRendererPrintsOneLinePerAccount(t *testing.T) {
+	var out strings.Builder
+	r, err := newAccountRenderer(outputOptions{Format: "table"}, true, &out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.write([]Account{{ID: "3_1", SafeName: "Linux", UserName: "root", Address: "db01", PlatformID: "UnixSSH"}})
+	r.write([]Account{{ID: "3_2", SafeName: "Linux", UserName: "oracle", Address: "db02", PlatformID: "UnixSSH"}})
+	if err := r.finish(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "Linux/oracle@db02") {
+		t.Errorf("compact output = %q, want one line per account", out.String())
+	}
+}
+
+func TestBulkDeleteWritesFailuresForRetry(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`{"value":[{"id":"3_1"},{"id":"3_2"},{"id":"3_3"}],"count":3}`))
+		case strings.HasSuffix(r.URL.Path, "/3_2"):
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"ErrorCode":"PASWS041E","ErrorMessage":"not authorized"}`))
+		default:
+			mu.Lock()
+			deleted = append(deleted, filepath.Base(r.URL.Path))
+			mu.Unlock()
+		}
+	}))
+	defer srv.Close()
+
+	failures := filepath.Join(t.TempDir(), "failures")
+	err := (&BulkDeleteWorkflow{}).Execute(newTestClient(t, srv), []string{"--safe", "Old", "--confirm", "--failures", failures})
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 accounts") {
+		t.Errorf("bulk-delete = %v, want 1 of 3 accounts failed", err)
+	}
+	if len(deleted) != 2 {
+		t.Errorf("deleted %v, want 3_1 and 3_3", deleted)
+	}
+	if data, err := os.ReadFile(failures); err != nil || string(data) != "3_2\n" {
+		t.Errorf("failures file = %q, %v; want 3_2", data, err)
+	}
+
+	deleted = nil
+	if err := (&BulkDeleteWorkflow{}).Execute(newTestClient(t, srv), []string{"--safe", "Old", "--dry-run"}); err != nil || len(deleted) != 0 {
+		t.Errorf("bulk-delete --dry-run = %v and deleted %v, want nothing deleted", err, deleted)
+	}
+}
+
+func TestMoveAccountKeepsTheSourceUntilTheCopyExists(t *testing.T) {
+	serve := func(createStatus int) (http.HandlerFunc, *[]string) {
+		var calls []string
+		return func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/PasswordVault/API/"))
+			switch {
+			case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/5_1"):
+				w.Write([]byte(`{"id":"5_1","userName":"root","address":"db1","platformId":"UnixSSH","safeName":"Old"}`))
+			case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/6_1"):
+				w.Write([]byte(`{"id":"6_1","userName":"root","address":"db1","platformId":"UnixSSH","safeName":"New"}`))
+			case r.Method == http.MethodGet:
+				w.Write([]byte(`{"value":[],"count":0}`))
+			case strings.HasSuffix(r.URL.Path, "/Password/Retrieve"):
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"ErrorCode":"PASWS013E","ErrorMessage":"Not authorized"}`))
+			case r.Method == http.MethodPost:
+				w.WriteHeader(createStatus)
+				w.Write([]byte(`{"id":"6_1"}`))
+			}
+		}, &calls
+	}
+	args := []string{"--id", "5_1", "--dest-safe", "New", "--with-secret", "--delete-source", "--yes"}
+
+	handler, calls := serve(http.StatusCreated)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	if err := (&MoveAccountWorkflow{}).Execute(newTestClient(t, srv), args); err != nil {
+		t.Fatalf("move-account = %v, want the secret skipped with a warning", err)
+	}
+	if last := (*calls)[len(*calls)-1]; last != "DELETE Accounts/5_1" {
+		t.Errorf("last request = %q, want the source deleted", last)
+	}
+
+	handler, calls = serve(http.StatusBadRequest)
+	srv = httptest.NewServer(handler)
+	defer srv.Close()
+	if err := (&MoveAccountWorkflow{}).Execute(newTestClient(t, srv), args); err == nil || !strings.Contains(err.Error(), "left as it is") {
+		t.Errorf("move-account with a failed create = %v, want the source left alone", err)
+	}
+	for _, c := range *calls {
+		if strings.HasPrefix(c, "DELETE") {
+			t.Errorf("a failed create was followed by %s", c)
+		}
+	}
+}
+
+func TestMissingRetrievalFieldsNamesTheFlags(t *testing.T) {
+	ticket := newAPIError(http.StatusForbidden, []byte(`{"ErrorCode":"ITATS542I","ErrorMessage":"A ticket ID and ticketing system are required"}`))
+	tests := []struct {
+		err  error
+		req  retrieveRequest
+		want string
+	}{
+		{ticket, retrieveRequest{}, "--ticket-id --ticket-system"},
+		{ticket, retrieveRequest{TicketID: "INC-1"}, "--ticket-system"},
+		{newAPIError(http.StatusForbidden, []byte(`{"ErrorMessage":"You must specify a reason"}`)), retrieveRequest{}, "--reason"},
+		{newAPIError(http.StatusForbidden, []byte(`{"ErrorMessage":"You must specify a reason"}`)), retrieveRequest{Reason: "x"}, ""},
+		{newAPIError(http.StatusInternalServerError, []byte(`{"ErrorMessage":"reason unknown"}`)), retrieveRequest{}, ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(missingRetrievalFields(tt.err, tt.req), " "); got != tt.want {
+			t.Errorf("missingRetrievalFields(%v, %+v) = %q, want %q", tt.err, tt.req, got, tt.want)
+		}
+	}
+}
+
+func TestDeleteAccountRetainHistoryChecksTheSafePolicy(t *testing.T) {
+	serve := func(safe string) (*httptest.Server, *bool) {
+		deleted := false
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodDelete:
+				deleted = true
+			case strings.Contains(r.URL.Path, "/Safes/"):
+				w.Write([]byte(safe))
+			default:
+				w.Write([]byte(`{"id":"5_1","userName":"root","address":"db1","safeName":"Linux"}`))
+			}
+		})), &deleted
+	}
+	args := []string{"--id", "5_1", "--retain-history", "--yes"}
+
+	srv, deleted := serve(`{"safeName":"Linux","numberOfVersionsRetention":5}`)
+	defer srv.Close()
+	err := (&DeleteAccountWorkflow{}).Execute(newTestClient(t, srv), args)
+	if err == nil || !strings.Contains(err.Error(), "a retention of 5 versions") || *deleted {
+		t.Errorf("delete-account on a version-retention safe = %v (deleted %v), want a refusal naming the policy", err, *deleted)
+	}
+
+	srv, deleted = serve(`{"safeName":"Linux","numberOfDaysRetention":7}`)
+	defer srv.Close()
+	if err := (&DeleteAccountWorkflow{}).Execute(newTestClient(t, srv), args); err != nil || !*deleted {
+		t.Errorf("delete-account on a 7-day retention safe = %v (deleted %v), want the account deleted", err, *deleted)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// ExportActivitiesWorkflow writes the activities of every account in a
+// safe within a date range to one CSV file.
+type ExportActivitiesWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("export-activities", &ExportActivitiesWorkflow{})
+}
+
+// exportActivitiesFlags are the flags of export-activities.
+type exportActivitiesFlags struct {
+	safe        string
+	fromFlag    string
+	toFlag      string
+	out         string
+	concurrency int
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *exportActivitiesFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("export-activities", "--safe NAME --from DATE --to DATE [--out FILE]")
+	fs.StringVar(&f.safe, "safe", "", "safe whose accounts to export (required)")
+	fs.StringVar(&f.fromFlag, "from", "", "start of the range, YYYY-MM-DD or RFC 3339 (required)")
+	fs.StringVar(&f.toFlag, "to", "", "end of the range, inclusive (required)")
+	fs.StringVar(&f.out, "out", "", "CSV file to write (default: stdout)")
+	fs.IntVar(&f.concurrency, "concurrency", 5, "number of accounts to fetch in parallel")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ExportActivitiesWorkflow) Synopsis() string {
+	return "write the activities of every account in a safe to a file"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ExportActivitiesWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(exportActivitiesFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ExportActivitiesWorkflow) Execute(client *APIClient, args []string) error {
+	var f exportActivitiesFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.safe == "" || f.fromFlag == "" || f.toFlag == "" {
+		fs.Usage()
+		return errors.New("--safe, --from and --to are required")
+	}
+	from, err := parseDate(f.fromFlag, false)
+	if err != nil {
+		return err
+	}
+	to, err := parseDate(f.toFlag, true)
+	if err != nil {
+		return err
+	}
+	if to.Before(from) {
+		return errors.New("--to is before --from")
+	}
+
+	var accounts []Account
+	err = fetchAccounts(client, safeFilter(f.safe), func(page []Account) error {
+		accounts = append(accounts, page...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list accounts in safe %s: %w", f.safe, err)
+	}
+
+	results := make([][]Activity, len(accounts))
+	index := make(map[string]int, len(accounts))
+	for i, a := range accounts {
+		index[a.ID] = i
+	}
+	prog := newProgress("Fetching activities", len(accounts))
+	ctx := client.baseContext()
+	errs := runConcurrent(ctx, accounts, f.concurrency, func(a Account) error {
+		defer prog.step()
+		activities, err := getActivities(client, a.ID)
+		if err != nil {
+			prog.warn("account %s: %v", a.ID, err)
+			return err
+		}
+		var inRange []Activity
+		for _, act := range activities {
+			if t := act.Time(); !t.Before(from) && !t.After(to) {
+				inRange = append(inRange, act)
+			}
+		}
+		// Each goroutine writes only its own slot.
+		results[index[a.ID]] = inRange
+		return nil
+	})
+	prog.finish()
+	if err := context.Cause(ctx); err != nil {
+		return fmt.Errorf("export stopped: %w", err)
+	}
+
+	dest := os.Stdout
+	if f.out != "" {
+		f, err := os.OpenFile(f.out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		dest = f
+	}
+	cw := csv.NewWriter(dest)
+	cw.Write([]string{"account_id", "account", "time", "action", "user", "reason"})
+	rows := 0
+	for i, a := range accounts {
+		acts := results[i]
+		sort.Slice(acts, func(x, y int) bool { return acts[x].Date < acts[y].Date })
+		for _, act := range acts {
+			cw.Write([]string{
+				a.ID,
+				fmt.Sprintf("%s/%s@%s", a.SafeName, a.UserName, a.Address),
+				act.Time().Format(time.RFC3339),
+				act.Action,
+				act.User,
+				act.Reason,
+			})
+			rows++
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d activities from %d accounts\n", rows, len(accounts))
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("activities of %d accounts could not be fetched", failed)
+	}
+	return nil
+}
+
+// ListActivitiesWorkflow prints the activity log of one account.
+type ListActivitiesWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("activities", &ListActivitiesWorkflow{})
+}
+
+// activitiesFlags are the flags of activities.
+type activitiesFlags struct {
+	id       string
+	fromFlag string
+	toFlag   string
+	limit    int
+	all      bool
+	output   outputOptions
+	checks   resultChecks
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *activitiesFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("activities", "--id ID [--from DATE] [--to DATE] [--limit N | --all] [--output FORMAT] [--fail-if-empty|--fail-if-nonempty]")
+	fs.StringVar(&f.id, "id", "", "account ID (required)")
+	fs.StringVar(&f.fromFlag, "from", "", "only show activities from this date, YYYY-MM-DD or RFC 3339")
+	fs.StringVar(&f.toFlag, "to", "", "only show activities up to this date, inclusive")
+	fs.IntVar(&f.limit, "limit", 50, "maximum number of activities to show, newest first")
+	fs.BoolVar(&f.all, "all", false, "show every activity in the range instead of stopping at --limit")
+	f.output.registerFlags(fs)
+	f.checks.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ListActivitiesWorkflow) Synopsis() string {
+	return "print the activity log of one account"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ListActivitiesWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(activitiesFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ListActivitiesWorkflow) Execute(client *APIClient, args []string) error {
+	f := activitiesFlags{output: outputOptions{Format: client.outputFormat}}
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" {
+		fs.Usage()
+		return errors.New("--id is required")
+	}
+	if f.all && isFlagSet(fs, "limit") {
+		return errors.New("--all and --limit cannot be combined")
+	}
+	if f.limit <= 0 {
+		return errors.New("--limit must be positive")
+	}
+	var from, to time.Time
+	var err error
+	if f.fromFlag != "" {
+		if from, err = parseDate(f.fromFlag, false); err != nil {
+			return err
+		}
+	}
+	if f.toFlag != "" {
+		if to, err = parseDate(f.toFlag, true); err != nil {
+			return err
+		}
+		if to.Before(from) {
+			return errors.New("--to is before --from")
+		}
+	}
+
+	columns := []column[Activity]{
+		{"TIME", func(a Activity) string { return a.Time().Format(time.RFC3339) }},
+		{"USER", func(a Activity) string { return a.User }},
+		{"ACTION", func(a Activity) string { return a.Action }},
+		{"REASON", func(a Activity) string { return valueOr(a.Reason, "-") }},
+	}
+	r, err := newRenderer(f.output, os.Stdout, columns)
+	if err != nil {
+		return err
+	}
+
+	// The vault returns the whole log in one response, so the range and
+	// the limit are applied here.
+	activities, err := getActivities(client, f.id)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("account %s not found", f.id)
+		}
+		return fmt.Errorf("failed to get the activities of account %s: %w", f.id, err)
+	}
+	var inRange []Activity
+	for _, act := range activities {
+		t := act.Time()
+		if (f.fromFlag == "" || !t.Before(from)) && (f.toFlag == "" || !t.After(to)) {
+			inRange = append(inRange, act)
+		}
+	}
+	sort.SliceStable(inRange, func(x, y int) bool { return inRange[x].Date > inRange[y].Date })
+	total := len(inRange)
+	if !f.all && total > f.limit {
+		inRange = inRange[:f.limit]
+	}
+
+	if len(inRange) == 0 {
+		fmt.Fprintf(os.Stderr, "No activities found for account %s\n", f.id)
+		return f.checks.check(0)
+	}
+	if err := r.write(inRange); err != nil {
+		return err
+	}
+	if err := r.finish(); err != nil {
+		return err
+	}
+	if total > len(inRange) {
+		fmt.Fprintf(os.Stderr, "Showing the newest %d of %d activities; raise --limit or pass --all to see the rest\n", len(inRange), total)
+	}
+	return f.checks.check(len(inRange))
+}
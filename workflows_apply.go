@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// findAccount returns the account in safe with the given user name and
+// address, matched ignoring case, or nil if there is none.
+func findAccount(client *APIClient, safe, username, address string) (*Account, error) {
+	params := safeFilter(safe)
+	params.Set("search", username+" "+address)
+	accounts, err := fetchAll[Account](client, "PasswordVault/API/Accounts", params)
+	if err != nil {
+		return nil, err
+	}
+	var found []Account
+	for _, a := range accounts {
+		if strings.EqualFold(a.UserName, username) && strings.EqualFold(a.Address, address) {
+			found = append(found, a)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &found[0], nil
+	}
+	ids := make([]string, len(found))
+	for i, a := range found {
+		ids[i] = a.ID
+	}
+	return nil, fmt.Errorf("%d accounts in safe %s match %s@%s (%s); use update-account --id instead",
+		len(found), safe, username, address, strings.Join(ids, ", "))
+}
+
+// accountChanges returns the operations that bring an account to the
+// desired state, comparing only the fields that were given, along with a
+// "path: old -> new" line describing each. Property values come back from
+// the API as strings or numbers, so they are compared in their printed
+// form.
+func accountChanges(a *Account, desired createAccountRequest) (ops []patchOp, changes []string) {
+	change := func(path string, from, to interface{}) {
+		ops = append(ops, patchOp{Op: "replace", Path: path, Value: to})
+		changes = append(changes, fmt.Sprintf("%s: %s -> %v", path, valueOr(fmt.Sprint(from), `""`), to))
+	}
+	replace := func(path, from, to string) {
+		if to != "" && to != from {
+			change(path, from, to)
+		}
+	}
+	replace("/name", a.Name, desired.Name)
+	replace("/platformId", a.PlatformID, desired.PlatformID)
+
+	names := make([]string, 0, len(desired.PlatformAccountProperties))
+	for name := range desired.PlatformAccountProperties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		current := ""
+		if v, ok := a.PlatformAccountProperties[name]; ok && v != nil {
+			current = fmt.Sprint(v)
+		}
+		replace(accountPropertiesPath+name, current, desired.PlatformAccountProperties[name])
+	}
+
+	if r := desired.RemoteMachinesAccess; r != nil {
+		var current RemoteMachinesAccess
+		if a.RemoteMachinesAccess != nil {
+			current = *a.RemoteMachinesAccess
+		}
+		replace("/remoteMachinesAccess/remoteMachines", current.RemoteMachines, r.RemoteMachines)
+		if r.AccessRestrictedToRemoteMachines != current.AccessRestrictedToRemoteMachines {
+			change("/remoteMachinesAccess/accessRestrictedToRemoteMachines", current.AccessRestrictedToRemoteMachines, r.AccessRestrictedToRemoteMachines)
+		}
+	}
+	return ops, changes
+}
+
+// ApplyAccountWorkflow makes sure an account exists with the given
+// properties, creating it or updating only what differs. Running it twice
+// with the same flags changes nothing the second time.
+type ApplyAccountWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("apply-account", &ApplyAccountWorkflow{})
+}
+
+// applyAccountFlags are the flags of apply-account.
+type applyAccountFlags struct {
+	safe           string
+	username       string
+	address        string
+	platform       string
+	name           string
+	secretType     string
+	secret         string
+	remoteMachines string
+	restricted     bool
+	properties     keyValueFlag
+	preview        bodyPreview
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *applyAccountFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("apply-account", "--safe NAME --username USER --address HOST --platform ID [options]")
+	fs.StringVar(&f.safe, "safe", "", "safe the account is in (required)")
+	fs.StringVar(&f.username, "username", "", "account user name (required)")
+	fs.StringVar(&f.address, "address", "", "target address (required)")
+	fs.StringVar(&f.platform, "platform", "", "platform ID (required)")
+	fs.StringVar(&f.name, "name", "", "account object name; when creating, the default is generated by the vault")
+	fs.StringVar(&f.secretType, "secret-type", "password", "secret type when creating: password or key")
+	fs.StringVar(&f.secret, "secret", "", "initial secret, only used when the account is created; - reads it from stdin, without echo at a terminal")
+	fs.StringVar(&f.remoteMachines, "remote-machines", "", "comma-separated machines PSM for SSH users may connect to")
+	fs.BoolVar(&f.restricted, "access-restricted-to-remote-machines", false, "only allow connections to --remote-machines")
+	f.properties = keyValueFlag{}
+	fs.Var(f.properties, "properties", "platform properties as key=value pairs, comma-separated or repeated")
+	f.preview.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ApplyAccountWorkflow) Synopsis() string {
+	return "create an account, or update it to match the given properties"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ApplyAccountWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(applyAccountFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ApplyAccountWorkflow) Execute(client *APIClient, args []string) error {
+	var f applyAccountFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.safe == "" || f.username == "" || f.address == "" || f.platform == "" {
+		fs.Usage()
+		return errors.New("--safe, --username, --address and --platform are required")
+	}
+	answer, err := secretFlag(f.secret, fmt.Sprintf("Secret for %s@%s: ", f.username, f.address))
+	if err != nil {
+		return err
+	}
+	f.secret = answer
+
+	desired := createAccountRequest{
+		Name:                      f.name,
+		Address:                   f.address,
+		UserName:                  f.username,
+		PlatformID:                f.platform,
+		SafeName:                  f.safe,
+		SecretType:                f.secretType,
+		Secret:                    f.secret,
+		PlatformAccountProperties: f.properties,
+	}
+	if f.remoteMachines != "" {
+		machines, err := parseRemoteMachines(f.remoteMachines)
+		if err != nil {
+			return err
+		}
+		desired.RemoteMachinesAccess = &RemoteMachinesAccess{
+			RemoteMachines:                   machines,
+			AccessRestrictedToRemoteMachines: f.restricted,
+		}
+	} else if f.restricted {
+		return errors.New("--access-restricted-to-remote-machines requires --remote-machines")
+	}
+
+	// Whether the account will be created or updated is not known yet, so
+	// both are needed.
+	err = client.precheck(safeNeed{f.safe, []string{"listAccounts", "addAccounts", "updateAccountProperties"}})
+	if err != nil {
+		return err
+	}
+	existing, err := findAccount(client, f.safe, f.username, f.address)
+	if err != nil {
+		return fmt.Errorf("failed to look up %s@%s in safe %s: %w", f.username, f.address, f.safe, err)
+	}
+
+	if existing == nil {
+		fmt.Printf("Account %s@%s does not exist in safe %s; creating it\n", f.username, f.address, f.safe)
+		const endpoint = "PasswordVault/API/Accounts"
+		if send, err := f.preview.show(client, http.MethodPost, endpoint, desired); !send || err != nil {
+			return err
+		}
+		data, err := client.Post(endpoint, desired)
+		if err != nil {
+			return fmt.Errorf("failed to create account: %w", err)
+		}
+		createdID, err := extractField(data, "id")
+		if err != nil {
+			return fmt.Errorf("account created but the response could not be parsed: %w", err)
+		}
+		fmt.Printf("Created account %s\n", createdID)
+		return nil
+	}
+
+	ops, changes := accountChanges(existing, desired)
+	if len(ops) == 0 {
+		fmt.Printf("Account %s is up to date\n", existing.ID)
+		return nil
+	}
+	fmt.Printf("Account %s differs; updating:\n", existing.ID)
+	for _, c := range changes {
+		fmt.Printf("  %s\n", c)
+	}
+	endpoint := "PasswordVault/API/Accounts/" + url.PathEscape(existing.ID)
+	if send, err := f.preview.show(client, http.MethodPatch, endpoint, ops); !send || err != nil {
+		return err
+	}
+	if _, err := client.Patch(endpoint, ops); err != nil {
+		return fmt.Errorf("failed to update account %s: %w", existing.ID, err)
+	}
+	fmt.Printf("Updated account %s\n", existing.ID)
+	return nil
+}
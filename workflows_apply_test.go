@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// applyServer serves apply-account: the search returns accounts, and
+// each change request is recorded as "METHOD path body".
+func applyServer(accounts string) (*httptest.Server, *[]string) {
+	var changes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"value":` + accounts + `}`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		changes = append(changes, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/PasswordVault/API/")+" "+string(body))
+		w.Write([]byte(`{"id":"9_1"}`))
+	}))
+	return srv, &changes
+}
+
+func TestApplyAccount(t *testing.T) {
+	const existing = `{"id":"5_1","name":"db1-root","userName":"Root","address":"DB1","platformId":"UnixSSH","safeName":"Linux",
+		"platformAccountProperties":{"Port":22}}`
+	args := []string{"--safe", "Linux", "--username", "root", "--address", "db1", "--platform", "UnixSSH", "--secret", "s3cret"}
+	tests := []struct {
+		name     string
+		accounts string
+		extra    []string
+		want     string // the change sent, or "" for none
+		wantErr  string
+	}{
+		{"create", `[{"id":"5_2","userName":"root","address":"db2"}]`, nil, `POST Accounts {"address":"db1","userName":"root","platformId":"UnixSSH","safeName":"Linux","secretType":"password","secret":"s3cret"`, ""},
+		{"no-op", `[` + existing + `]`, []string{"--properties", "Port=22"}, "", ""},
+		{"update", `[` + existing + `]`, []string{"--platform", "UnixSSH2", "--properties", "Port=2222"},
+			`PATCH Accounts/5_1 [{"op":"replace","path":"/platformId","value":"UnixSSH2"},{"op":"replace","path":"/platformAccountProperties/Port","value":"2222"}]`, ""},
+		{"ambiguous", `[` + existing + `,{"id":"5_7","userName":"root","address":"db1"}]`, nil, "", "2 accounts in safe Linux match root@db1 (5_1, 5_7)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, changes := applyServer(tt.accounts)
+			defer srv.Close()
+			err := (&ApplyAccountWorkflow{}).Execute(newTestClient(t, srv), append(append([]string{}, args...), tt.extra...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("apply-account = %v, want an error containing %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Join(*changes, "\n")
+			if tt.want == "" && got != "" || !strings.HasPrefix(got, tt.want) {
+				t.Errorf("apply-account sent %s\nwant %s", valueOr(got, "nothing"), valueOr(tt.want, "nothing"))
+			}
+		})
+	}
+}
+
+func TestAccountChangesComparesOnlyGivenFields(t *testing.T) {
+	var a Account
+	if err := json.Unmarshal([]byte(`{"name":"db1-root","platformId":"UnixSSH","platformAccountProperties":{"Port":22,"Location":"\\Servers"}}`), &a); err != nil {
+		t.Fatal(err)
+	}
+	ops, changes := accountChanges(&a, createAccountRequest{PlatformAccountProperties: map[string]string{"Port": "22", "LogonDomain": "corp"}})
+	if len(ops) != 1 || ops[0].Path != "/platformAccountProperties/LogonDomain" || changes[0] != `/platformAccountProperties/LogonDomain: "" -> corp` {
+		t.Errorf("accountChanges = %v, %v, want only the new LogonDomain", ops, changes)
+	}
+}
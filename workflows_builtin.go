@@ -0,0 +1,387 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// VerifyWorkflow checks that the configuration loaded correctly, that the
+// PVWA can be reached, that the credentials log on and that the session
+// can call the API and, with --server, that the PVWA supports the
+// configuration. Each failure is reported as its own kind of problem.
+type VerifyWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("verify", &VerifyWorkflow{})
+}
+
+// sessionless implements sessionless: verify logs on itself, so a cached
+// session cannot hide a logon that no longer works.
+func (w *VerifyWorkflow) sessionless() {}
+
+// verifyFlags are the flags of verify.
+type verifyFlags struct {
+	server bool
+	otp    string
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *verifyFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("verify", "[--server] [--otp CODE]")
+	fs.BoolVar(&f.server, "server", false, "also check the configuration against the PVWA's version and logon methods")
+	fs.StringVar(&f.otp, "otp", "", "one-time passcode for a RADIUS logon challenge, instead of a prompt")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *VerifyWorkflow) Synopsis() string {
+	return "check that the configuration can reach and log on to the PVWA"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *VerifyWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(verifyFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *VerifyWorkflow) Execute(client *APIClient, args []string) error {
+	var f verifyFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	base := client.config.BaseURL
+	fmt.Printf("Configuration loaded for %s\n", base)
+	if !client.config.logsOn() {
+		return errors.New("config: username is required to log on")
+	}
+
+	// The server information endpoint needs no session, so it tells an
+	// unreachable host apart from a failed logon. Some PVWAs block it; any
+	// HTTP response still shows the host is reachable.
+	info, err := getServerInfo(client)
+	switch {
+	case err == nil:
+		fmt.Printf("Reached %s (PVWA %s)\n", base, valueOr(info.ExternalVersion, "version unknown"))
+	case apiStatus(err) != 0:
+		fmt.Printf("Reached %s (server information not available: status %d)\n", base, apiStatus(err))
+		info = nil
+	case isConnectionFailure(err):
+		return connectionFailure(base, err)
+	default:
+		return err
+	}
+
+	if f.otp != "" {
+		client.AuthPrompt = fixedAuthResponse(f.otp)
+	}
+	if _, err := client.Logon(); err != nil {
+		if isConnectionFailure(err) {
+			return connectionFailure(base, err)
+		}
+		return fmt.Errorf("authentication failed for %s: %w", valueOr(client.config.Username, "the current Windows user"), err)
+	}
+	if client.cache == nil {
+		defer func() {
+			if err := client.Logoff(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+	}
+	how := "session token received"
+	if client.token == "" {
+		how = "session cookie received"
+	}
+	fmt.Printf("Logged on as %s with %s authentication (%s)\n", valueOr(client.config.Username, "the current Windows user"), client.config.authMethodName(), how)
+
+	// Listing a single safe is about the cheapest call any user may make,
+	// so it shows that the session is accepted beyond the logon.
+	if _, err := client.GetWithParams("PasswordVault/API/Safes", url.Values{"limit": {"1"}}); err != nil {
+		if isConnectionFailure(err) {
+			return connectionFailure(base, err)
+		}
+		return fmt.Errorf("logged on, but the API refused a request with the new session: %w", err)
+	}
+	fmt.Println("API request with the session succeeded")
+
+	if f.server {
+		if info == nil {
+			return validateServer(client)
+		}
+		printServerChecks(client, info)
+	}
+	return nil
+}
+
+// isTLSError reports whether err is a failed TLS handshake: an untrusted or
+// mismatched server certificate, a client certificate the server refused,
+// or a server that does not speak TLS on that port.
+func isTLSError(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	var alert tls.AlertError
+	var header tls.RecordHeaderError
+	return errors.As(err, &certErr) || errors.As(err, &alert) || errors.As(err, &header)
+}
+
+// isConnectionFailure reports whether err means no response was received.
+func isConnectionFailure(err error) bool {
+	return errors.Is(err, errTransport) || isTLSError(err)
+}
+
+// connectionFailure describes a request to base that got no response,
+// telling TLS problems apart from a host that cannot be reached at all.
+func connectionFailure(base string, err error) error {
+	if isTLSError(err) {
+		return fmt.Errorf("TLS error connecting to %s (check ca_cert_path, or client_cert_path if the PVWA requires one): %w", base, err)
+	}
+	return fmt.Errorf("cannot reach %s: %w", base, err)
+}
+
+// LogoffWorkflow ends the cached session and deletes the token cache.
+type LogoffWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("logoff", &LogoffWorkflow{})
+}
+
+// sessionless implements sessionless: logging on just to log off would be
+// pointless.
+func (w *LogoffWorkflow) sessionless() {}
+
+// logoffFlags are the flags of logoff.
+type logoffFlags struct {
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *logoffFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("logoff", "")
+
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *LogoffWorkflow) Synopsis() string {
+	return "end the cached session and delete the token cache"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *LogoffWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(logoffFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *LogoffWorkflow) Execute(client *APIClient, args []string) error {
+	var f logoffFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// The cache is read whatever token_cache_ttl says now, since the
+	// token may have been cached under an earlier setting.
+	cache := &tokenCache{path: client.config.TokenCachePath}
+	if token, ok := cache.load(client.config); ok {
+		client.token = token
+		if err := client.Logoff(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	removed, err := cache.remove()
+	if err != nil {
+		return fmt.Errorf("failed to remove token cache: %w", err)
+	}
+	if !removed {
+		fmt.Println("No cached session")
+		return nil
+	}
+	fmt.Printf("Logged off and removed %s\n", cache.path)
+	return nil
+}
+
+// initConfig is the config file InitWorkflow writes: only the settings
+// needed to log on, which the README documents alongside the rest.
+type initConfig struct {
+	BaseURL    string `json:"base_url"`
+	Username   string `json:"username,omitempty"`
+	APISecret  string `json:"api_secret,omitempty"`
+	AuthMethod string `json:"auth_method,omitempty"`
+}
+
+// InitWorkflow writes a new config file from answers to a few prompts, or
+// from its flags, and can then verify it.
+type InitWorkflow struct {
+	path string
+}
+
+func init() {
+	RegisterWorkflow("init", &InitWorkflow{})
+}
+
+// configless implements configless: init is how the config comes to exist.
+func (w *InitWorkflow) configless() {}
+
+// setConfigPath implements configWriter.
+func (w *InitWorkflow) setConfigPath(path string) { w.path = path }
+
+// initFlags are the flags of init.
+type initFlags struct {
+	c      initConfig
+	force  bool
+	verify bool
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *initFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("init", "[--base-url URL] [--auth-method METHOD] [--username NAME] [--secret -|env:VAR] [--force] [--verify]")
+	fs.StringVar(&f.c.BaseURL, "base-url", "", "PVWA address, e.g. https://pvwa.example.com")
+	fs.StringVar(&f.c.AuthMethod, "auth-method", "", "cyberark, ldap, radius or windows (default cyberark)")
+	fs.StringVar(&f.c.Username, "username", "", "user to log on as")
+	fs.StringVar(&f.c.APISecret, "secret", "", "password or API secret; - to read it from stdin, or env:VAR to have each run read it from $VAR")
+	fs.BoolVar(&f.force, "force", false, "overwrite an existing config file")
+	fs.BoolVar(&f.verify, "verify", false, "run verify with the new config without asking")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *InitWorkflow) Synopsis() string {
+	return "write a new config file"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *InitWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(initFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *InitWorkflow) Execute(client *APIClient, args []string) error {
+	var f initFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path := valueOr(w.path, defaultConfigPath())
+	if path == "-" {
+		return errors.New("init writes a file; pass its path with --config, not -")
+	}
+	if _, err := os.Stat(path); err == nil && !f.force {
+		return fmt.Errorf("%s already exists; pass --force to overwrite it", path)
+	}
+
+	if err := askInitConfig(&f.c); err != nil {
+		return err
+	}
+	// Validating a copy reports mistakes before anything is written, and
+	// normalizes base_url and auth_method for the file.
+	check := Config{BaseURL: f.c.BaseURL, Username: f.c.Username, APISecret: f.c.APISecret, AuthMethod: f.c.AuthMethod}
+	if err := check.validate(); err != nil {
+		return err
+	}
+	f.c.BaseURL = check.BaseURL
+	if check.AuthMethod != "cyberark" {
+		f.c.AuthMethod = check.AuthMethod
+	}
+
+	data, err := json.MarshalIndent(f.c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writePrivateFile(path, append(data, '\n'), f.force); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", path)
+	if info, err := os.Stat(path); err == nil {
+		if err := checkPrivate(path, info, "config file"); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	if !f.verify {
+		if !isTerminal(os.Stdin) {
+			return nil
+		}
+		if f.verify, err = confirm("Verify the new config now?"); err != nil || !f.verify {
+			return err
+		}
+	}
+	config, err := loadConfig(path, defaultProfile)
+	if err != nil {
+		return err
+	}
+	verifyClient, err := NewAPIClient(config)
+	if err != nil {
+		return err
+	}
+	return (&VerifyWorkflow{}).Execute(verifyClient, nil)
+}
+
+// askInitConfig prompts for the fields of c that its flags left empty.
+// Without a terminal every field the auth method needs must come from the
+// flags; validation then names the missing one.
+func askInitConfig(c *initConfig) error {
+	secret, err := secretFlag(c.APISecret, "Password or API secret: ")
+	if err != nil {
+		return err
+	}
+	c.APISecret = secret
+	if !isTerminal(os.Stdin) {
+		return nil
+	}
+	if c.BaseURL == "" {
+		if c.BaseURL, err = ask("PVWA address (e.g. https://pvwa.example.com): "); err != nil {
+			return err
+		}
+	}
+	if c.AuthMethod == "" {
+		if c.AuthMethod, err = ask("Authentication method, cyberark, ldap, radius or windows [cyberark]: "); err != nil {
+			return err
+		}
+	}
+	windows := strings.EqualFold(strings.TrimSpace(c.AuthMethod), "windows")
+	if c.Username == "" {
+		prompt := "Username: "
+		if windows {
+			prompt = "Username (empty for the current Windows user): "
+		}
+		if c.Username, err = ask(prompt); err != nil {
+			return err
+		}
+	}
+	if c.APISecret == "" && !windows {
+		if c.APISecret, err = askSecret("Password or API secret (or env:VAR to read it from $VAR on each run): "); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePrivateFile writes data to a new file at path that only its owner
+// can read, or with overwrite over an existing one, which is then made
+// private too.
+func writePrivateFile(path string, data []byte, overwrite bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if overwrite {
+		err = f.Chmod(0o600)
+	}
+	if err == nil {
+		_, err = f.Write(data)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
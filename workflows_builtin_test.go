@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyReportsWhichStepFailed(t *testing.T) {
+	serve := func(logon, safes int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/Logon"):
+				w.WriteHeader(logon)
+				w.Write([]byte(`"token"`))
+			case r.URL.Path == "/PasswordVault/API/Safes":
+				w.WriteHeader(safes)
+				w.Write([]byte(`{"value":[],"count":0}`))
+			default:
+				w.Write([]byte(`{"ExternalVersion":"14.0.0"}`))
+			}
+		}
+	}
+	tests := []struct {
+		name string
+		srv  *httptest.Server
+		want string
+	}{
+		{"untrusted certificate", httptest.NewTLSServer(serve(http.StatusOK, http.StatusOK)), "TLS error connecting to"},
+		{"logon refused", httptest.NewServer(serve(http.StatusUnauthorized, http.StatusOK)), "authentication failed for svc"},
+		{"API refused", httptest.NewServer(serve(http.StatusOK, http.StatusForbidden)), "logged on, but the API refused"},
+		{"all good", httptest.NewServer(serve(http.StatusOK, http.StatusOK)), ""},
+	}
+	for _, tt := range tests {
+		defer tt.srv.Close()
+		err := (&VerifyWorkflow{}).Execute(newTestClient(t, tt.srv), nil)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: verify = %v, want nil", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: verify = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestInitWritesAPrivateConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	wf := &InitWorkflow{path: path}
+	args := []string{"--base-url", "https://pvwa.example.com/PasswordVault/", "--username", "svc", "--secret", "env:CYBERARK_TEST_SECRET"}
+	if err := wf.Execute(nil, args); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPrivate(path, info, "config file"); err != nil {
+		t.Error(err)
+	}
+	data, _ := os.ReadFile(path)
+	var got initConfig
+	if err := json.Unmarshal(data, &got); err != nil || got.BaseURL != "https://pvwa.example.com" || got.APISecret != "env:CYBERARK_TEST_SECRET" {
+		t.Errorf("config = %s (%v), want the normalized base_url and the env: secret", data, err)
+	}
+
+	if err := wf.Execute(nil, args); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("init over an existing file = %v, want a hint about --force", err)
+	}
+	if err := wf.Execute(nil, []string{"--base-url", "https://pvwa.example.com", "--force"}); err == nil {
+		t.Error("init without a secret succeeded")
+	}
+}
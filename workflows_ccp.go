@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+// CCPGetWorkflow prints a password retrieved from the Central Credential
+// Provider.
+type CCPGetWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("ccp-get", &CCPGetWorkflow{})
+}
+
+// sessionless implements sessionless; the CCP authenticates the
+// application itself.
+func (w *CCPGetWorkflow) sessionless() {}
+
+// ccpGetFlags are the flags of ccp-get. The flags default to the values
+// the fields hold when flagSet is called, that is to the ccp settings.
+type ccpGetFlags struct {
+	req     CCPRequest
+	cert    string
+	key     string
+	baseURL string
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *ccpGetFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("ccp-get", "--app-id ID --safe NAME (--object NAME | --query QUERY) [--cert FILE --key FILE]")
+	fs.StringVar(&f.req.AppID, "app-id", f.req.AppID, "application ID the CCP authenticates (default ccp.app_id)")
+	fs.StringVar(&f.req.Safe, "safe", "", "safe holding the account")
+	fs.StringVar(&f.req.Folder, "folder", "", "folder in the safe")
+	fs.StringVar(&f.req.Object, "object", "", "name of the account object")
+	fs.StringVar(&f.req.Query, "query", "", "query such as \"Username=svc;Address=db01\", instead of --object")
+	fs.StringVar(&f.req.Reason, "reason", "", "reason recorded with the retrieval")
+	fs.StringVar(&f.cert, "cert", f.cert, "PEM client certificate for mutual TLS (default ccp.cert_path)")
+	fs.StringVar(&f.key, "key", f.key, "PEM key of --cert (default ccp.key_path)")
+	fs.StringVar(&f.baseURL, "url", f.baseURL, "CCP address (default ccp.url, then base_url)")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *CCPGetWorkflow) Synopsis() string {
+	return "print a password from the Central Credential Provider"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *CCPGetWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(ccpGetFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *CCPGetWorkflow) Execute(client *APIClient, args []string) error {
+	settings := CCPConfig{}
+	if client.config.CCP != nil {
+		settings = *client.config.CCP
+	}
+	f := ccpGetFlags{
+		req:     CCPRequest{AppID: settings.AppID},
+		cert:    settings.CertPath,
+		key:     settings.KeyPath,
+		baseURL: valueOr(settings.URL, client.config.BaseURL),
+	}
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case f.req.AppID == "":
+		fs.Usage()
+		return errors.New("--app-id is required")
+	case (f.req.Object == "") == (f.req.Query == ""):
+		fs.Usage()
+		return errors.New("exactly one of --object and --query is required")
+	case f.baseURL == "":
+		return errors.New("no CCP address: set ccp.url or base_url in the config, or pass --url")
+	case (f.cert == "") != (f.key == ""):
+		return errors.New("--cert and --key must be given together")
+	}
+
+	transport := client.httpClient.Transport
+	if f.cert != "" {
+		var err error
+		if transport, err = withClientCert(transport, f.cert, f.key); err != nil {
+			return err
+		}
+	}
+	account, err := NewCCPClient(f.baseURL, transport, client).GetAccount(f.req)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve password: %w", err)
+	}
+	fmt.Println(account.Content)
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// ConjurGetWorkflow prints the value of a Conjur variable.
+type ConjurGetWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("conjur-get", &ConjurGetWorkflow{})
+}
+
+// sessionless implements sessionless; Conjur has its own authentication.
+func (w *ConjurGetWorkflow) sessionless() {}
+
+// conjurGetFlags are the flags of conjur-get.
+type conjurGetFlags struct {
+	id string
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *conjurGetFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("conjur-get", "--id VARIABLE")
+	fs.StringVar(&f.id, "id", "", "variable id, e.g. prod/db/password (required)")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ConjurGetWorkflow) Synopsis() string {
+	return "print the value of a Conjur variable"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ConjurGetWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(conjurGetFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ConjurGetWorkflow) Execute(client *APIClient, args []string) error {
+	var f conjurGetFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" {
+		fs.Usage()
+		return errors.New("--id is required")
+	}
+	if client.config.Conjur == nil {
+		return errors.New("conjur-get requires a \"conjur\" section in the config")
+	}
+
+	secret, err := NewConjurClient(client.config.Conjur, client).GetSecret(f.id)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve %s: %w", f.id, err)
+	}
+	os.Stdout.Write(secret)
+	fmt.Println()
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// Component is a component instance returned by
+// GET /ComponentsMonitoringDetails/{componentId}.
+type Component struct {
+	UserName      string `json:"ComponentUserName"`
+	Version       string `json:"ComponentVersion"`
+	IP            string `json:"IP"`
+	IsLoggedOn    bool   `json:"IsLoggedOn"`
+	LastLogonDate int64  `json:"LastLogonDate"`
+}
+
+// listComponents returns every instance of a component type, such as CPM
+// or PSM, known to the vault.
+func listComponents(client *APIClient, componentID string) ([]Component, error) {
+	result, err := GetInto[struct {
+		ComponentsDetails []Component `json:"ComponentsDetails"`
+	}](client, "PasswordVault/API/ComponentsMonitoringDetails/"+componentID)
+	if err != nil {
+		return nil, err
+	}
+	return result.ComponentsDetails, nil
+}
+
+// ListCPMsWorkflow lists the Central Policy Managers and whether each one
+// is connected to the vault.
+type ListCPMsWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("list-cpms", &ListCPMsWorkflow{})
+}
+
+// listCpmsFlags are the flags of list-cpms.
+type listCpmsFlags struct {
+	output outputOptions
+	checks resultChecks
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *listCpmsFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("list-cpms", "[--output FORMAT] [--fail-if-empty|--fail-if-nonempty]")
+	f.output.registerFlags(fs)
+	f.checks.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ListCPMsWorkflow) Synopsis() string {
+	return "list the Central Policy Managers and whether each one is connected"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ListCPMsWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(listCpmsFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ListCPMsWorkflow) Execute(client *APIClient, args []string) error {
+	f := listCpmsFlags{output: outputOptions{Format: client.outputFormat}}
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	columns := []column[Component]{
+		{"NAME", func(c Component) string { return c.UserName }},
+		{"ADDRESS", func(c Component) string { return valueOr(c.IP, "-") }},
+		{"VERSION", func(c Component) string { return valueOr(c.Version, "-") }},
+		{"STATUS", func(c Component) string { return componentStatus(c) }},
+		{"LAST LOGON", func(c Component) string { return formatEpoch(c.LastLogonDate) }},
+	}
+	r, err := newRenderer(f.output, os.Stdout, columns)
+	if err != nil {
+		return err
+	}
+
+	cpms, err := listComponents(client, "CPM")
+	if err != nil {
+		return fmt.Errorf("failed to list CPMs: %w", err)
+	}
+	if len(cpms) == 0 {
+		fmt.Fprintln(os.Stderr, "No CPMs found")
+		return f.checks.check(0)
+	}
+	if err := r.write(cpms); err != nil {
+		return err
+	}
+	if err := r.finish(); err != nil {
+		return err
+	}
+	// A disconnected CPM stops rotating every account in the safes it
+	// manages, so make it stand out from the table.
+	for _, c := range cpms {
+		if !c.IsLoggedOn {
+			fmt.Fprintf(os.Stderr, "Warning: CPM %s is disconnected; accounts in the safes it manages will not be rotated\n", c.UserName)
+		}
+	}
+	return f.checks.check(len(cpms))
+}
+
+// componentStatus describes whether a component is logged on to the vault.
+func componentStatus(c Component) string {
+	if c.IsLoggedOn {
+		return "connected"
+	}
+	return "disconnected"
+}
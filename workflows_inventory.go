@@ -0,0 +1,222 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// InventoryWorkflow writes every account in every safe the caller can
+// see, fetching the safes' accounts in parallel.
+type InventoryWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("inventory", &InventoryWorkflow{})
+}
+
+// errInventoryFull stops paging through a safe once max_results accounts
+// have been inventoried.
+var errInventoryFull = errors.New("max_results reached")
+
+// inventoryColumns are the CSV columns of inventory; jsonl has the whole
+// account.
+var inventoryColumns = []column[Account]{
+	{"safe", func(a Account) string { return a.SafeName }},
+	{"id", func(a Account) string { return a.ID }},
+	{"name", func(a Account) string { return a.Name }},
+	{"username", func(a Account) string { return a.UserName }},
+	{"address", func(a Account) string { return a.Address }},
+	{"platform", func(a Account) string { return a.PlatformID }},
+	{"automatic_management", func(a Account) string { return strconv.FormatBool(a.SecretManagement.AutomaticManagementEnabled) }},
+	{"status", func(a Account) string { return a.SecretManagement.Status }},
+}
+
+// inventoryFlags are the flags of inventory.
+type inventoryFlags struct {
+	format      string
+	out         string
+	concurrency int
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *inventoryFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("inventory", "[--output jsonl|csv] [--out FILE] [--concurrency N]")
+	fs.StringVar(&f.format, "output", "jsonl", "output format: jsonl or csv")
+	fs.StringVar(&f.out, "out", "", "file to write, gzip-compressed if the name ends in .gz (default: stdout)")
+	fs.IntVar(&f.concurrency, "concurrency", 5, "number of safes to fetch in parallel")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *InventoryWorkflow) Synopsis() string {
+	return "write every account in every safe you can see"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *InventoryWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(inventoryFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *InventoryWorkflow) Execute(client *APIClient, args []string) error {
+	var f inventoryFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.format != "jsonl" && f.format != "csv" {
+		return fmt.Errorf("invalid --output %q: must be jsonl or csv", f.format)
+	}
+
+	safes, err := listSafes(client)
+	if err != nil {
+		return fmt.Errorf("failed to list safes: %w", err)
+	}
+
+	var dest io.Writer = os.Stdout
+	file, err := createOutputFile(f.out)
+	if err != nil {
+		return err
+	}
+	// The deferred close only matters on error paths; the explicit one
+	// below catches a failed final write.
+	defer file.close()
+	if file != nil {
+		dest = file
+	}
+	r, err := newRenderer(outputOptions{Format: f.format}, dest, inventoryColumns)
+	if err != nil {
+		return err
+	}
+
+	// Pages from different safes arrive concurrently; each is written
+	// whole so rows of one safe are not interleaved with another's.
+	// max_results caps the whole inventory rather than each safe, so the
+	// count of accounts written is shared and the safes are paged through
+	// without a cap of their own.
+	var mu sync.Mutex
+	accounts, skipped, truncated := 0, 0, false
+	maxResults := client.config.MaxResults
+	prog := newProgress("Fetching safes", len(safes))
+	ctx := client.baseContext()
+	errs := runConcurrent(ctx, safes, f.concurrency, func(s Safe) error {
+		defer prog.step()
+		mu.Lock()
+		full := truncated
+		mu.Unlock()
+		if full {
+			return nil
+		}
+		err := pageThrough(client, "PasswordVault/API/Accounts", safeFilter(s.SafeName), 0, func(page []Account) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if maxResults > 0 && accounts+len(page) > maxResults {
+				page, truncated = page[:maxResults-accounts], true
+			}
+			accounts += len(page)
+			if err := r.write(page); err != nil {
+				return err
+			}
+			if truncated {
+				return errInventoryFull
+			}
+			return nil
+		})
+		if errors.Is(err, errInventoryFull) {
+			return nil
+		}
+		if apiStatus(err) == http.StatusForbidden {
+			prog.warn("skipping safe %s: not allowed to list its accounts", s.SafeName)
+			mu.Lock()
+			skipped++
+			mu.Unlock()
+			return nil
+		}
+		if err != nil {
+			prog.warn("safe %s: %v", s.SafeName, err)
+		}
+		return err
+	})
+	prog.finish()
+	if err := r.finish(); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	if err := file.close(); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Inventoried %d accounts in %d safes (%d skipped)\n", accounts, len(safes)-skipped, skipped)
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Warning: inventory truncated at %d accounts by max_results; raise --max-results (0 for no limit) to see the rest\n", accounts)
+	}
+	if err := context.Cause(ctx); err != nil {
+		return fmt.Errorf("inventory stopped before every safe was read: %w", err)
+	}
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d safes could not be inventoried", failed, len(safes))
+	}
+	return nil
+}
+
+// outputFile is a file written by an export workflow. Names ending in .gz
+// are compressed as they are written, so large exports are never held in
+// memory. A nil outputFile stands for stdout and closes as a no-op.
+type outputFile struct {
+	f  *os.File
+	gz *gzip.Writer
+}
+
+// createOutputFile creates path, readable only by the owner. It returns a
+// nil outputFile when path is empty.
+func createOutputFile(path string) (*outputFile, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	o := &outputFile{f: f}
+	if strings.HasSuffix(path, ".gz") {
+		o.gz = gzip.NewWriter(f)
+	}
+	return o, nil
+}
+
+func (o *outputFile) Write(p []byte) (int, error) {
+	if o.gz != nil {
+		return o.gz.Write(p)
+	}
+	return o.f.Write(p)
+}
+
+// close ends the gzip stream, if any, and closes the file. Calling it
+// again does nothing.
+func (o *outputFile) close() error {
+	if o == nil || o.f == nil {
+		return nil
+	}
+	var err error
+	if o.gz != nil {
+		err = o.gz.Close()
+	}
+	if cerr := o.f.Close(); err == nil {
+		err = cerr
+	}
+	o.f, o.gz = nil, nil
+	return err
+}
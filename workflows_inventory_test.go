@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInventoryMaxResultsCapsTheWholeInventory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/Safes") {
+			w.Write([]byte(`{"value":[{"safeName":"A"},{"safeName":"B"},{"safeName":"C"}],"count":3}`))
+			return
+		}
+		safe := strings.TrimPrefix(r.URL.Query().Get("filter"), "safeName eq ")
+		fmt.Fprintf(w, `{"value":[{"id":"%[1]s1"},{"id":"%[1]s2"},{"id":"%[1]s3"}],"count":3}`, safe)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv)
+	client.config.MaxResults = 4
+	out := filepath.Join(t.TempDir(), "inventory.jsonl")
+	if err := (&InventoryWorkflow{}).Execute(client, []string{"--out", out}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 4 {
+		t.Errorf("inventory wrote %d accounts, want max_results of them across all safes:\n%s", lines, data)
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Platform is one entry of GET /Platforms.
+type Platform struct {
+	General struct {
+		ID           string `json:"id"`
+		Name         string `json:"name"`
+		SystemType   string `json:"systemType"`
+		PlatformType string `json:"platformType"`
+		Active       bool   `json:"active"`
+		Description  string `json:"description,omitempty"`
+	} `json:"general"`
+}
+
+// listPlatforms returns the platforms defined in the vault, only the active
+// ones when active is set.
+func listPlatforms(client *APIClient, active bool) ([]Platform, error) {
+	params := url.Values{}
+	if active {
+		params.Set("active", "true")
+	}
+	result, err := GetInto[struct {
+		Platforms []Platform `json:"Platforms"`
+	}](client, withQuery("PasswordVault/API/Platforms", params))
+	if err != nil {
+		return nil, err
+	}
+	// Older vaults ignore the active parameter, so filter here as well.
+	if active {
+		kept := result.Platforms[:0]
+		for _, p := range result.Platforms {
+			if p.General.Active {
+				kept = append(kept, p)
+			}
+		}
+		result.Platforms = kept
+	}
+	return result.Platforms, nil
+}
+
+// PlatformProperty is an account property defined by a platform.
+type PlatformProperty struct {
+	Name        string `json:"Name"`
+	DisplayName string `json:"DisplayName"`
+}
+
+// PlatformDetails is the response of GET /Platforms/{platformId}.
+type PlatformDetails struct {
+	PlatformID string `json:"PlatformID"`
+	General    struct {
+		Name         string `json:"Name"`
+		SystemType   string `json:"SystemType"`
+		PlatformType string `json:"PlatformType"`
+		Active       bool   `json:"Active"`
+	} `json:"General"`
+	Properties struct {
+		Required []PlatformProperty `json:"Required"`
+		Optional []PlatformProperty `json:"Optional"`
+	} `json:"Properties"`
+}
+
+// getPlatform fetches a platform's details and account properties.
+func getPlatform(client *APIClient, id string) (*PlatformDetails, error) {
+	p, err := GetInto[PlatformDetails](client, "PasswordVault/API/Platforms/"+url.PathEscape(id))
+	if err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("platform %s not found", id)
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// platformBuiltinProperties are platform properties that create-account
+// sets from its own flags rather than from --properties, keyed in lower
+// case, with the flag that sets each.
+var platformBuiltinProperties = map[string]string{
+	"address":  "--address",
+	"username": "--username",
+	"password": "--secret",
+}
+
+// checkAccountProperties compares create-account's --properties with what
+// the platform defines. It rejects properties the platform does not know
+// and required ones that were not given.
+func checkAccountProperties(p *PlatformDetails, properties map[string]string) error {
+	known := map[string]bool{}
+	var missing []string
+	for _, prop := range p.Properties.Required {
+		known[strings.ToLower(prop.Name)] = true
+		if _, builtin := platformBuiltinProperties[strings.ToLower(prop.Name)]; builtin {
+			continue
+		}
+		if _, ok := properties[prop.Name]; !ok {
+			missing = append(missing, prop.Name)
+		}
+	}
+	for _, prop := range p.Properties.Optional {
+		known[strings.ToLower(prop.Name)] = true
+	}
+
+	var unknown []string
+	for name := range properties {
+		if flagName, builtin := platformBuiltinProperties[strings.ToLower(name)]; builtin {
+			return fmt.Errorf("set %s with %s, not --properties", name, flagName)
+		}
+		if !known[strings.ToLower(name)] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "missing required properties "+strings.Join(missing, ", "))
+	}
+	if len(unknown) > 0 {
+		problems = append(problems, "unknown properties "+strings.Join(unknown, ", "))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("platform %s: %s (see describe-platform --id %s)", p.PlatformID, strings.Join(problems, "; "), p.PlatformID)
+	}
+	return nil
+}
+
+// ListPlatformsWorkflow lists the platforms accounts can be created with.
+type ListPlatformsWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("list-platforms", &ListPlatformsWorkflow{})
+}
+
+// listPlatformsFlags are the flags of list-platforms.
+type listPlatformsFlags struct {
+	active bool
+	output outputOptions
+	checks resultChecks
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *listPlatformsFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("list-platforms", "[--active] [--output FORMAT] [--fail-if-empty|--fail-if-nonempty]")
+	fs.BoolVar(&f.active, "active", false, "only list active platforms")
+	f.output.registerFlags(fs)
+	f.checks.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ListPlatformsWorkflow) Synopsis() string {
+	return "list the platforms accounts can be created with"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ListPlatformsWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(listPlatformsFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ListPlatformsWorkflow) Execute(client *APIClient, args []string) error {
+	f := listPlatformsFlags{output: outputOptions{Format: client.outputFormat}}
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	columns := []column[Platform]{
+		{"ID", func(p Platform) string { return p.General.ID }},
+		{"NAME", func(p Platform) string { return p.General.Name }},
+		{"SYSTEM TYPE", func(p Platform) string { return valueOr(p.General.SystemType, "-") }},
+		{"TYPE", func(p Platform) string { return valueOr(p.General.PlatformType, "-") }},
+		{"ACTIVE", func(p Platform) string { return fmt.Sprint(p.General.Active) }},
+	}
+	r, err := newRenderer(f.output, os.Stdout, columns)
+	if err != nil {
+		return err
+	}
+
+	platforms, err := listPlatforms(client, f.active)
+	if err != nil {
+		return fmt.Errorf("failed to list platforms: %w", err)
+	}
+	if len(platforms) == 0 {
+		fmt.Fprintln(os.Stderr, "No platforms found")
+		return f.checks.check(0)
+	}
+	sort.Slice(platforms, func(i, j int) bool { return platforms[i].General.ID < platforms[j].General.ID })
+	if err := r.write(platforms); err != nil {
+		return err
+	}
+	if err := r.finish(); err != nil {
+		return err
+	}
+	return f.checks.check(len(platforms))
+}
+
+// platformPropertyRow is one row of describe-platform's output.
+type platformPropertyRow struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Required    bool   `json:"required"`
+	SetWith     string `json:"setWith"`
+}
+
+// DescribePlatformWorkflow shows a platform's required and optional
+// account properties. It is registered as both describe-platform and
+// get-platform.
+type DescribePlatformWorkflow struct {
+	name string
+}
+
+func init() {
+	RegisterWorkflow("describe-platform", &DescribePlatformWorkflow{name: "describe-platform"})
+	RegisterWorkflow("get-platform", &DescribePlatformWorkflow{name: "get-platform"})
+}
+
+// describePlatformFlags are the flags of describe-platform and get-platform.
+type describePlatformFlags struct {
+	id     string
+	output outputOptions
+	checks resultChecks
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *describePlatformFlags) flagSet(name string) *flag.FlagSet {
+	fs := newFlagSet(name, "--id PLATFORM [--output FORMAT] [--fail-if-empty|--fail-if-nonempty]")
+	fs.StringVar(&f.id, "id", "", "platform ID, e.g. WinDomain (required)")
+	f.output.registerFlags(fs)
+	f.checks.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *DescribePlatformWorkflow) Synopsis() string {
+	return "show a platform's required and optional account properties"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *DescribePlatformWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(describePlatformFlags).flagSet(w.name))
+}
+
+// Execute implements Workflow.
+func (w *DescribePlatformWorkflow) Execute(client *APIClient, args []string) error {
+	f := describePlatformFlags{output: outputOptions{Format: client.outputFormat}}
+	fs := f.flagSet(w.name)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" {
+		fs.Usage()
+		return errors.New("--id is required")
+	}
+
+	columns := []column[platformPropertyRow]{
+		{"PROPERTY", func(r platformPropertyRow) string { return r.Name }},
+		{"DISPLAY NAME", func(r platformPropertyRow) string { return valueOr(r.DisplayName, "-") }},
+		{"REQUIRED", func(r platformPropertyRow) string {
+			if r.Required {
+				return "yes"
+			}
+			return "no"
+		}},
+		{"SET WITH", func(r platformPropertyRow) string { return r.SetWith }},
+	}
+	r, err := newRenderer(f.output, os.Stdout, columns)
+	if err != nil {
+		return err
+	}
+
+	p, err := getPlatform(client, f.id)
+	if err != nil {
+		return err
+	}
+	// The summary only suits the table; the other formats carry just the
+	// property rows, so they stay machine-readable.
+	if f.output.Format == "table" && f.output.GroupBy == "" {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Platform:\t%s\n", valueOr(p.PlatformID, f.id))
+		fmt.Fprintf(tw, "Name:\t%s\n", valueOr(p.General.Name, "-"))
+		fmt.Fprintf(tw, "System type:\t%s\n", valueOr(p.General.SystemType, "-"))
+		fmt.Fprintf(tw, "Active:\t%t\n\n", p.General.Active)
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	var rows []platformPropertyRow
+	add := func(props []PlatformProperty, required bool) {
+		for _, prop := range props {
+			with := "--properties " + prop.Name + "=..."
+			if flagName, builtin := platformBuiltinProperties[strings.ToLower(prop.Name)]; builtin {
+				with = flagName
+			}
+			rows = append(rows, platformPropertyRow{Name: prop.Name, DisplayName: prop.DisplayName, Required: required, SetWith: with})
+		}
+	}
+	add(p.Properties.Required, true)
+	add(p.Properties.Optional, false)
+	if err := r.write(rows); err != nil {
+		return err
+	}
+	if err := r.finish(); err != nil {
+		return err
+	}
+	return f.checks.check(len(rows))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// redactedHeaders are response headers whose values are never printed by
+// raw --include-headers.
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// rawTemplateData holds the config values --endpoint and --body can
+// refer to as {{.Field}}. It is its own type so the API secret can never
+// be expanded into a request, however the template is written.
+type rawTemplateData struct {
+	BaseURL  string
+	Username string
+	Timeout  int
+}
+
+// rawEscapes escape the config values expanded into each raw flag, so a
+// user name such as "a&b" or `a"b` cannot change the request around it:
+// in --endpoint as a path segment or query value, in --body as the
+// contents of a JSON string.
+var rawEscapes = map[string]func(string) string{
+	"endpoint": func(s string) string { return strings.ReplaceAll(url.QueryEscape(s), "+", "%20") },
+	"body": func(s string) string {
+		var b strings.Builder
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		enc.Encode(s)
+		quoted := strings.TrimSuffix(b.String(), "\n")
+		return quoted[1 : len(quoted)-1]
+	},
+}
+
+// expandRawTemplate expands {{.Field}} placeholders in a raw flag value,
+// escaping each value for the flag it is expanded into. Values without
+// placeholders are returned unchanged.
+func expandRawTemplate(flagName, value string, config *Config) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+	tmpl, err := template.New(flagName).Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid template in --%s: %w", flagName, err)
+	}
+	var out strings.Builder
+	escape := rawEscapes[flagName]
+	data := rawTemplateData{BaseURL: escape(config.BaseURL), Username: escape(config.Username), Timeout: config.Timeout}
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to expand --%s (available: {{.BaseURL}}, {{.Username}}, {{.Timeout}}): %w", flagName, err)
+	}
+	return out.String(), nil
+}
+
+// RawWorkflow sends an arbitrary request to the API and prints the
+// response body, for endpoints that have no dedicated workflow.
+type RawWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("raw", &RawWorkflow{})
+}
+
+// rawFlags are the flags of raw.
+type rawFlags struct {
+	method         string
+	endpoint       string
+	body           string
+	includeHeaders bool
+	indent         bool
+	allowSecrets   bool
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *rawFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("raw", "--endpoint PATH [--method METHOD] [--body JSON] [--include-headers]")
+	fs.StringVar(&f.method, "method", http.MethodGet, "HTTP method")
+	fs.StringVar(&f.endpoint, "endpoint", "", "endpoint relative to base_url, e.g. PasswordVault/API/Safes (required);\n{{.Username}}, {{.BaseURL}} and {{.Timeout}} expand from the config, URL-encoded")
+	fs.StringVar(&f.body, "body", "", "JSON request body; expands the same placeholders as --endpoint, JSON-escaped")
+	fs.BoolVar(&f.includeHeaders, "include-headers", false, "print the status line and response headers before the body")
+	registerJSONIndentFlag(fs, &f.indent)
+	fs.BoolVar(&f.allowSecrets, "allow-secrets", false, "print the response even if it contains secret-like fields such as password")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *RawWorkflow) Synopsis() string {
+	return "send any request to the API and print the response"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *RawWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(rawFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *RawWorkflow) Execute(client *APIClient, args []string) error {
+	var f rawFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.endpoint == "" {
+		fs.Usage()
+		return errors.New("--endpoint is required")
+	}
+	var err error
+	if f.endpoint, err = expandRawTemplate("endpoint", f.endpoint, client.config); err != nil {
+		return err
+	}
+	if f.body, err = expandRawTemplate("body", f.body, client.config); err != nil {
+		return err
+	}
+
+	var payload interface{}
+	if f.body != "" {
+		if !json.Valid([]byte(f.body)) {
+			return errors.New("--body is not valid JSON")
+		}
+		payload = json.RawMessage(f.body)
+	}
+
+	resp, respBody, err := client.send(client.baseContext(), strings.ToUpper(f.method), strings.TrimPrefix(f.endpoint, "/"), payload)
+	if err != nil {
+		return err
+	}
+
+	// raw can reach any endpoint, so it is the easiest way to dump a
+	// credential by accident; make that deliberate.
+	if !f.allowSecrets {
+		if path := findSecret(respBody); path != "" {
+			return fmt.Errorf("%w; pass --allow-secrets if that is intended", errSecretInOutput(path))
+		}
+	}
+
+	if f.includeHeaders {
+		printResponseHead(resp)
+	}
+	// Bodies that are not JSON, such as error pages, are printed as is.
+	if !json.Valid(respBody) || writeJSON(os.Stdout, respBody, f.indent) != nil {
+		os.Stdout.Write(respBody)
+		if len(respBody) > 0 && respBody[len(respBody)-1] != '\n' {
+			fmt.Println()
+		}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("API error (status %d)", resp.StatusCode)
+	}
+	return nil
+}
+
+// printResponseHead writes the status line and headers in the style of
+// curl -i, followed by a blank line.
+func printResponseHead(resp *http.Response) {
+	fmt.Printf("%s %s\n", resp.Proto, resp.Status)
+	names := make([]string, 0, len(resp.Header))
+	for name := range resp.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range resp.Header[name] {
+			if redactedHeaders[name] {
+				value = "[REDACTED]"
+			}
+			fmt.Printf("%s: %s\n", name, value)
+		}
+	}
+	fmt.Println()
+}
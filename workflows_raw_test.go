@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRawRefusesARetrievedPassword(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`"Pa55w0rd!"`))
+	}))
+	defer srv.Close()
+
+	args := []string{"--method", "POST", "--endpoint", "PasswordVault/API/Accounts/12_3/Password/Retrieve"}
+	err := (&RawWorkflow{}).Execute(newTestClient(t, srv), args)
+	if err == nil || !strings.Contains(err.Error(), "(response body)") || !strings.Contains(err.Error(), "--allow-secrets") {
+		t.Errorf("raw retrieve = %v, want a refusal naming the response body", err)
+	}
+	if err := (&RawWorkflow{}).Execute(newTestClient(t, srv), append(args, "--allow-secrets")); err != nil {
+		t.Errorf("raw retrieve --allow-secrets = %v", err)
+	}
+}
+
+func TestExpandRawTemplateEscapesValues(t *testing.T) {
+	config := &Config{BaseURL: "https://pvwa.example.com", Username: `o'brien&co "x"/y`, Timeout: 30}
+	tests := []struct {
+		flag, value, want string
+	}{
+		{"endpoint", "PasswordVault/API/Users?search={{.Username}}&limit=1", "PasswordVault/API/Users?search=o%27brien%26co%20%22x%22%2Fy&limit=1"},
+		{"endpoint", "PasswordVault/API/Users/{{.Username}}/Groups", "PasswordVault/API/Users/o%27brien%26co%20%22x%22%2Fy/Groups"},
+		{"body", `{"userName":"{{.Username}}","timeout":{{.Timeout}}}`, `{"userName":"o'brien&co \"x\"/y","timeout":30}`},
+		{"endpoint", "PasswordVault/API/Safes", "PasswordVault/API/Safes"},
+	}
+	for _, tt := range tests {
+		got, err := expandRawTemplate(tt.flag, tt.value, config)
+		if err != nil || got != tt.want {
+			t.Errorf("expandRawTemplate(%s, %q) = %q, %v, want %q", tt.flag, tt.value, got, err, tt.want)
+		}
+	}
+}
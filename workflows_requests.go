@@ -0,0 +1,386 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// AccessRequest is a request for access to an account, as returned by
+// GET /IncomingRequests.
+type AccessRequest struct {
+	RequestID         string `json:"RequestID"`
+	SafeName          string `json:"SafeName"`
+	RequestorUserName string `json:"RequestorUserName"`
+	RequestorReason   string `json:"RequestorReason"`
+	AccessFrom        int64  `json:"AccessFrom"`
+	AccessTo          int64  `json:"AccessTo"`
+	StatusTitle       string `json:"StatusTitle"`
+	AccountDetails    struct {
+		Properties struct {
+			Address  string `json:"Address"`
+			UserName string `json:"UserName"`
+		} `json:"Properties"`
+	} `json:"AccountDetails"`
+}
+
+// listIncomingRequests returns the access requests the caller can act on.
+func listIncomingRequests(client *APIClient, onlyWaiting bool) ([]AccessRequest, error) {
+	q := url.Values{"onlywaiting": {fmt.Sprint(onlyWaiting)}, "expired": {"false"}}
+	result, err := GetInto[struct {
+		IncomingRequests []AccessRequest `json:"IncomingRequests"`
+	}](client, withQuery("PasswordVault/API/IncomingRequests", q))
+	if err != nil {
+		return nil, err
+	}
+	return result.IncomingRequests, nil
+}
+
+// ListMyApprovalsWorkflow lists the access requests waiting for the
+// caller's approval.
+type ListMyApprovalsWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("list-my-approvals", &ListMyApprovalsWorkflow{})
+}
+
+// listMyApprovalsFlags are the flags of list-my-approvals.
+type listMyApprovalsFlags struct {
+	all    bool
+	checks resultChecks
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *listMyApprovalsFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("list-my-approvals", "[--all] [--fail-if-empty|--fail-if-nonempty]")
+	fs.BoolVar(&f.all, "all", false, "include requests that were already answered")
+	f.checks.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ListMyApprovalsWorkflow) Synopsis() string {
+	return "list the access requests waiting for your approval"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ListMyApprovalsWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(listMyApprovalsFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ListMyApprovalsWorkflow) Execute(client *APIClient, args []string) error {
+	var f listMyApprovalsFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	requests, err := listIncomingRequests(client, !f.all)
+	if err != nil {
+		return fmt.Errorf("failed to list incoming requests: %w", err)
+	}
+	if len(requests) == 0 {
+		fmt.Fprintln(os.Stderr, "No requests awaiting approval")
+		return f.checks.check(0)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	header := "REQUEST ID\tREQUESTOR\tACCOUNT\tFROM\tTO\tREASON"
+	if f.all {
+		header += "\tSTATUS"
+	}
+	fmt.Fprintln(tw, header)
+	for _, r := range requests {
+		props := r.AccountDetails.Properties
+		line := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s", r.RequestID, r.RequestorUserName,
+			fmt.Sprintf("%s/%s@%s", r.SafeName, props.UserName, props.Address),
+			formatEpoch(r.AccessFrom), formatEpoch(r.AccessTo), valueOr(r.RequestorReason, "-"))
+		if f.all {
+			line += "\t" + valueOr(r.StatusTitle, "-")
+		}
+		fmt.Fprintln(tw, line)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return f.checks.check(len(requests))
+}
+
+// AnswerRequestWorkflow approves or denies an incoming access request.
+type AnswerRequestWorkflow struct {
+	name   string
+	action string // "Confirm" or "Reject"
+	verb   string
+}
+
+func init() {
+	RegisterWorkflow("approve-request", &AnswerRequestWorkflow{name: "approve-request", action: "Confirm", verb: "Approved"})
+	RegisterWorkflow("deny-request", &AnswerRequestWorkflow{name: "deny-request", action: "Reject", verb: "Denied"})
+}
+
+// answerRequestFlags are the flags of approve-request and deny-request.
+type answerRequestFlags struct {
+	id     string
+	reason string
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *answerRequestFlags) flagSet(name string) *flag.FlagSet {
+	fs := newFlagSet(name, "--request-id ID [--reason TEXT]")
+	fs.StringVar(&f.id, "request-id", "", "ID of the request, as shown by list-my-approvals (required)")
+	fs.StringVar(&f.reason, "reason", "", "reason recorded with the answer and shown to the requestor")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *AnswerRequestWorkflow) Synopsis() string {
+	if w.action == "Reject" {
+		return "deny a request for access that awaits your approval"
+	}
+	return "approve a request for access that awaits your approval"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *AnswerRequestWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(answerRequestFlags).flagSet(w.name))
+}
+
+// Execute implements Workflow.
+func (w *AnswerRequestWorkflow) Execute(client *APIClient, args []string) error {
+	var f answerRequestFlags
+	fs := f.flagSet(w.name)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.id == "" {
+		fs.Usage()
+		return errors.New("--request-id is required")
+	}
+
+	endpoint := "PasswordVault/API/IncomingRequests/" + url.PathEscape(f.id) + "/" + w.action
+	if _, err := client.Post(endpoint, map[string]string{"Reason": f.reason}); err != nil {
+		switch apiStatus(err) {
+		case http.StatusNotFound:
+			return fmt.Errorf("request %s not found, or it is not waiting for your approval", f.id)
+		case http.StatusForbidden:
+			return fmt.Errorf("not allowed to answer request %s", f.id)
+		}
+		if state := requestState(err); state != "" {
+			return fmt.Errorf("cannot answer request %s: %s", f.id, state)
+		}
+		return fmt.Errorf("failed to answer request %s: %w", f.id, err)
+	}
+	fmt.Printf("%s request %s\n", w.verb, f.id)
+	return nil
+}
+
+// createAccessRequest is the body of POST /MyRequests. FromDate and
+// ToDate are Unix seconds; without them the request is for access now.
+type createAccessRequest struct {
+	AccountID              string `json:"AccountId"`
+	Reason                 string `json:"Reason"`
+	TicketingSystemName    string `json:"TicketingSystemName,omitempty"`
+	TicketID               string `json:"TicketId,omitempty"`
+	MultipleAccessRequired bool   `json:"MultipleAccessRequired"`
+	FromDate               int64  `json:"FromDate,omitempty"`
+	ToDate                 int64  `json:"ToDate,omitempty"`
+}
+
+// CreateAccessRequestWorkflow asks for access to an account in a safe
+// that requires dual control. Once an approver confirms it, the secret
+// can be retrieved with get-password.
+type CreateAccessRequestWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("create-access-request", &CreateAccessRequestWorkflow{})
+}
+
+// createAccessRequestFlags are the flags of create-access-request.
+type createAccessRequestFlags struct {
+	body     createAccessRequest
+	fromFlag string
+	toFlag   string
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *createAccessRequestFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("create-access-request", "--id ID --reason TEXT [--from DATE --to DATE] [--multiple-access]")
+	fs.StringVar(&f.body.AccountID, "id", "", "account ID (required)")
+	fs.StringVar(&f.body.Reason, "reason", "", "reason shown to the approvers (required)")
+	fs.StringVar(&f.body.TicketID, "ticket-id", "", "ticket authorizing the access, where the platform requires one")
+	fs.StringVar(&f.body.TicketingSystemName, "ticket-system", "", "ticketing system the --ticket-id is checked against")
+	fs.StringVar(&f.fromFlag, "from", "", "start of the access window, YYYY-MM-DD or RFC 3339 (default: now)")
+	fs.StringVar(&f.toFlag, "to", "", "end of the access window, inclusive (required with --from)")
+	fs.BoolVar(&f.body.MultipleAccessRequired, "multiple-access", false, "ask to use the account more than once within the window")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *CreateAccessRequestWorkflow) Synopsis() string {
+	return "ask for access to an account in a dual control safe"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *CreateAccessRequestWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(createAccessRequestFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *CreateAccessRequestWorkflow) Execute(client *APIClient, args []string) error {
+	var f createAccessRequestFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.body.AccountID == "" || f.body.Reason == "" {
+		fs.Usage()
+		return errors.New("--id and --reason are required")
+	}
+	if f.fromFlag != "" && f.toFlag == "" {
+		return errors.New("--from requires --to")
+	}
+	if f.toFlag != "" {
+		from := time.Now()
+		if f.fromFlag != "" {
+			var err error
+			if from, err = parseDate(f.fromFlag, false); err != nil {
+				return err
+			}
+		}
+		to, err := parseDate(f.toFlag, true)
+		if err != nil {
+			return err
+		}
+		if !to.After(from) {
+			return errors.New("--to must be after --from")
+		}
+		f.body.FromDate, f.body.ToDate = from.Unix(), to.Unix()
+	}
+
+	created, err := PostInto[AccessRequest](client, "PasswordVault/API/MyRequests", f.body)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("account %s not found", f.body.AccountID)
+		}
+		if state := requestState(err); state != "" {
+			return fmt.Errorf("cannot request access to account %s: %s", f.body.AccountID, state)
+		}
+		if apiStatus(err) == http.StatusConflict {
+			return fmt.Errorf("you already have an open request for account %s; see list-my-requests", f.body.AccountID)
+		}
+		return fmt.Errorf("failed to request access to account %s: %w", f.body.AccountID, err)
+	}
+	fmt.Printf("Created request %s for account %s; waiting for approval\n", valueOr(created.RequestID, "-"), f.body.AccountID)
+	return nil
+}
+
+// ListMyRequestsWorkflow lists the caller's own access requests, so a
+// requestor can see whether one has been answered.
+type ListMyRequestsWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("list-my-requests", &ListMyRequestsWorkflow{})
+}
+
+// listMyRequestsFlags are the flags of list-my-requests.
+type listMyRequestsFlags struct {
+	waiting bool
+	expired bool
+	output  outputOptions
+	checks  resultChecks
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *listMyRequestsFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("list-my-requests", "[--waiting] [--expired] [--output FORMAT] [--fail-if-empty|--fail-if-nonempty]")
+	fs.BoolVar(&f.waiting, "waiting", false, "only list requests that have not been answered yet")
+	fs.BoolVar(&f.expired, "expired", false, "include requests whose access window has passed")
+	f.output.registerFlags(fs)
+	f.checks.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ListMyRequestsWorkflow) Synopsis() string {
+	return "list your own access requests"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ListMyRequestsWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(listMyRequestsFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ListMyRequestsWorkflow) Execute(client *APIClient, args []string) error {
+	f := listMyRequestsFlags{output: outputOptions{Format: client.outputFormat}}
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	columns := []column[AccessRequest]{
+		{"REQUEST ID", func(r AccessRequest) string { return r.RequestID }},
+		{"ACCOUNT", func(r AccessRequest) string {
+			props := r.AccountDetails.Properties
+			return fmt.Sprintf("%s/%s@%s", r.SafeName, props.UserName, props.Address)
+		}},
+		{"FROM", func(r AccessRequest) string { return formatEpoch(r.AccessFrom) }},
+		{"TO", func(r AccessRequest) string { return formatEpoch(r.AccessTo) }},
+		{"STATUS", func(r AccessRequest) string { return valueOr(r.StatusTitle, "-") }},
+	}
+	r, err := newRenderer(f.output, os.Stdout, columns)
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{"onlywaiting": {fmt.Sprint(f.waiting)}, "expired": {fmt.Sprint(f.expired)}}
+	result, err := GetInto[struct {
+		MyRequests []AccessRequest `json:"MyRequests"`
+	}](client, withQuery("PasswordVault/API/MyRequests", q))
+	if err != nil {
+		return fmt.Errorf("failed to list your requests: %w", err)
+	}
+	if len(result.MyRequests) == 0 {
+		fmt.Fprintln(os.Stderr, "No access requests found")
+		return f.checks.check(0)
+	}
+	if err := r.write(result.MyRequests); err != nil {
+		return err
+	}
+	if err := r.finish(); err != nil {
+		return err
+	}
+	return f.checks.check(len(result.MyRequests))
+}
+
+// requestState describes an access request refused because of the state
+// it is in, such as one already answered or past its window, or returns
+// "" for any other error.
+func requestState(err error) string {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusConflict) {
+		return ""
+	}
+	text := strings.ToLower(apiErr.Message)
+	if text == "" {
+		text = strings.ToLower(string(apiErr.Body))
+	}
+	switch {
+	case strings.Contains(text, "expired"):
+		return "the request has expired; the requestor must create a new one"
+	case !strings.Contains(text, "already"):
+		return ""
+	case strings.Contains(text, "confirm") || strings.Contains(text, "approv"):
+		return "the request was already approved"
+	case strings.Contains(text, "reject") || strings.Contains(text, "denied"):
+		return "the request was already denied"
+	}
+	return ""
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRequestStateExplainsAnsweredRequests(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{newAPIError(http.StatusBadRequest, []byte(`{"ErrorCode":"ITATS036E","ErrorMessage":"Request has expired"}`)), "the request has expired; the requestor must create a new one"},
+		{newAPIError(http.StatusBadRequest, []byte(`{"ErrorMessage":"The request was already confirmed by Administrator"}`)), "the request was already approved"},
+		{newAPIError(http.StatusConflict, []byte(`{"ErrorMessage":"Request already rejected"}`)), "the request was already denied"},
+		{newAPIError(http.StatusBadRequest, []byte(`{"ErrorMessage":"Invalid request ID"}`)), ""},
+		{newAPIError(http.StatusForbidden, []byte(`{"ErrorMessage":"Request has expired"}`)), ""},
+		{errors.New("connection refused"), ""},
+	}
+	for _, tt := range tests {
+		if got := requestState(tt.err); got != tt.want {
+			t.Errorf("requestState(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// safeSnapshot is the definition of one safe as written by export-safes
+// and read by import-safes.
+type safeSnapshot struct {
+	Safe
+	Members []snapshotMember `json:"members"`
+}
+
+// snapshotMember is a safe member in a snapshot. SearchIn is not returned
+// by the API; it may be edited in the file for members that live in an
+// external directory and defaults to Vault.
+type snapshotMember struct {
+	SafeMember
+	SearchIn string `json:"searchIn,omitempty"`
+}
+
+// ExportSafesWorkflow writes safe definitions and members to a file.
+type ExportSafesWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("export-safes", &ExportSafesWorkflow{})
+}
+
+// exportSafesFlags are the flags of export-safes.
+type exportSafesFlags struct {
+	out  string
+	only string
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *exportSafesFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("export-safes", "--out FILE [--safes A,B]")
+	fs.StringVar(&f.out, "out", "", "file to write the snapshot to (required)")
+	fs.StringVar(&f.only, "safes", "", "comma-separated safes to export (default: all)")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ExportSafesWorkflow) Synopsis() string {
+	return "write safe definitions and members to a file"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ExportSafesWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(exportSafesFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ExportSafesWorkflow) Execute(client *APIClient, args []string) error {
+	var f exportSafesFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.out == "" {
+		fs.Usage()
+		return errors.New("--out is required")
+	}
+
+	var safes []Safe
+	if names := splitList(f.only); len(names) > 0 {
+		for _, name := range names {
+			safe, err := getSafe(client, name)
+			if err != nil {
+				return fmt.Errorf("failed to read safe %s: %w", name, err)
+			}
+			safes = append(safes, *safe)
+		}
+	} else {
+		var err error
+		if safes, err = listSafes(client); err != nil {
+			return fmt.Errorf("failed to list safes: %w", err)
+		}
+	}
+
+	snapshot := make([]safeSnapshot, 0, len(safes))
+	for _, safe := range safes {
+		members, err := listSafeMembers(client, safe.SafeName)
+		if err != nil {
+			return fmt.Errorf("failed to list members of safe %s: %w", safe.SafeName, err)
+		}
+		entry := safeSnapshot{Safe: safe, Members: []snapshotMember{}}
+		entry.SafeURLID, entry.SafeNumber = "", 0
+		oneRetentionPolicy(&entry.Safe)
+		for _, m := range members {
+			// Predefined members are added by the vault to every safe.
+			if !m.IsPredefinedUser {
+				entry.Members = append(entry.Members, snapshotMember{SafeMember: m})
+			}
+		}
+		snapshot = append(snapshot, entry)
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(f.out, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.out, err)
+	}
+	fmt.Printf("Exported %d safes to %s\n", len(snapshot), f.out)
+	return nil
+}
+
+// safeImportPlan is what import-safes will do for one safe.
+type safeImportPlan struct {
+	snapshot safeSnapshot
+	action   string // "create", "update" or "skip"
+	add      []snapshotMember
+	update   []snapshotMember
+}
+
+// ImportSafesWorkflow recreates safes from an export-safes file.
+type ImportSafesWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("import-safes", &ImportSafesWorkflow{})
+}
+
+// importSafesFlags are the flags of import-safes.
+type importSafesFlags struct {
+	file       string
+	update     bool
+	dryRun     bool
+	scriptPath string
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *importSafesFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("import-safes", "--file FILE [--update] [--dry-run] [--record-script FILE]")
+	fs.StringVar(&f.file, "file", "", "snapshot written by export-safes (required)")
+	fs.BoolVar(&f.update, "update", false, "update the properties and member permissions of existing safes")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "print the import plan without changing anything")
+	fs.StringVar(&f.scriptPath, "record-script", "", "write the equivalent individual commands to this shell script, also with --dry-run")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ImportSafesWorkflow) Synopsis() string {
+	return "recreate safes from an export-safes file"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ImportSafesWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(importSafesFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ImportSafesWorkflow) Execute(client *APIClient, args []string) error {
+	var f importSafesFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.file == "" {
+		fs.Usage()
+		return errors.New("--file is required")
+	}
+
+	data, err := os.ReadFile(f.file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.file, err)
+	}
+	var snapshot []safeSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", f.file, err)
+	}
+
+	plans, err := planSafeImport(client, snapshot, f.update)
+	if err != nil {
+		return err
+	}
+	if f.scriptPath != "" {
+		rec, err := newScriptRecorder(f.scriptPath, "import-safes")
+		if err != nil {
+			return err
+		}
+		for _, plan := range plans {
+			recordSafeImport(rec, plan)
+		}
+		if err := rec.close(); err != nil {
+			return err
+		}
+	}
+	if f.dryRun {
+		printSafeImportPlan(plans)
+		return nil
+	}
+
+	failed := 0
+	for _, plan := range plans {
+		if err := applySafeImport(client, plan); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: safe %s: %v\n", plan.snapshot.SafeName, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d safes could not be imported", failed, len(plans))
+	}
+	return nil
+}
+
+// planSafeImport compares the snapshot with the vault and decides what to
+// create, update or leave alone.
+func planSafeImport(client *APIClient, snapshot []safeSnapshot, update bool) ([]safeImportPlan, error) {
+	safes, err := listSafes(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list safes: %w", err)
+	}
+	exists := make(map[string]bool, len(safes))
+	for _, s := range safes {
+		exists[strings.ToLower(s.SafeName)] = true
+	}
+
+	plans := make([]safeImportPlan, 0, len(snapshot))
+	for _, snap := range snapshot {
+		if snap.SafeName == "" {
+			return nil, errors.New("snapshot contains a safe without a safeName")
+		}
+		// Snapshots may be hand-edited or come from an older export.
+		oneRetentionPolicy(&snap.Safe)
+		plan := safeImportPlan{snapshot: snap, action: "create"}
+		if !exists[strings.ToLower(snap.SafeName)] {
+			plan.add = snap.Members
+			plans = append(plans, plan)
+			continue
+		}
+
+		plan.action = "skip"
+		if update {
+			plan.action = "update"
+		}
+		current, err := listSafeMembers(client, snap.SafeName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list members of safe %s: %w", snap.SafeName, err)
+		}
+		byName := make(map[string]SafeMember, len(current))
+		for _, m := range current {
+			byName[strings.ToLower(m.MemberName)] = m
+		}
+		for _, m := range snap.Members {
+			cur, ok := byName[strings.ToLower(m.MemberName)]
+			switch {
+			case !ok:
+				plan.add = append(plan.add, m)
+			case update && !samePermissions(cur.Permissions, m.Permissions):
+				plan.update = append(plan.update, m)
+			}
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+func printSafeImportPlan(plans []safeImportPlan) {
+	for _, plan := range plans {
+		switch plan.action {
+		case "create":
+			fmt.Printf("create safe %s\n", plan.snapshot.SafeName)
+		case "update":
+			fmt.Printf("update safe %s\n", plan.snapshot.SafeName)
+		default:
+			fmt.Printf("skip safe %s (already exists)\n", plan.snapshot.SafeName)
+		}
+		for _, m := range plan.add {
+			fmt.Printf("  add member %s\n", m.MemberName)
+		}
+		for _, m := range plan.update {
+			fmt.Printf("  update member %s\n", m.MemberName)
+		}
+	}
+}
+
+// recordSafeImport records the commands that carry out one safe's plan.
+// Permission changes on existing members have no workflow of their own, so
+// they are recorded as raw requests.
+func recordSafeImport(rec *scriptRecorder, plan safeImportPlan) {
+	name := plan.snapshot.SafeName
+	switch plan.action {
+	case "create":
+		rec.record(append([]string{"create-safe", "--name", name}, safeArgs(plan.snapshot.Safe)...)...)
+	case "update":
+		rec.record(append([]string{"update-safe", "--name", name}, safeArgs(plan.snapshot.Safe)...)...)
+	}
+	for _, m := range plan.add {
+		args := []string{"add-safe-member", "--safe", name, "--member", m.MemberName,
+			"--member-type", valueOr(m.MemberType, "User"), "--search-in", valueOr(m.SearchIn, "Vault")}
+		for _, p := range safePermissions {
+			if m.Permissions[p] {
+				args = append(args, "--"+permissionFlagName(p))
+			}
+		}
+		rec.record(args...)
+	}
+	for _, m := range plan.update {
+		body, _ := json.Marshal(map[string]interface{}{"permissions": m.Permissions})
+		rec.record("raw", "--method", "PUT", "--endpoint", safeMembersEndpoint(name)+"/"+url.PathEscape(m.MemberName), "--body", string(body))
+	}
+}
+
+// applySafeImport carries out one safe's plan. Members are only processed
+// once the safe itself exists.
+func applySafeImport(client *APIClient, plan safeImportPlan) error {
+	name := plan.snapshot.SafeName
+	body := plan.snapshot.Safe
+	switch plan.action {
+	case "create":
+		if _, err := client.Post("PasswordVault/API/Safes", body); err != nil {
+			return fmt.Errorf("failed to create safe: %w", err)
+		}
+		fmt.Printf("Created safe %s\n", name)
+	case "update":
+		if _, err := client.Put(safeEndpoint(name), body); err != nil {
+			return fmt.Errorf("failed to update safe: %w", err)
+		}
+		fmt.Printf("Updated safe %s\n", name)
+	default:
+		fmt.Printf("Skipped safe %s (already exists)\n", name)
+	}
+
+	var errs []error
+	for _, m := range plan.add {
+		_, err := client.Post(safeMembersEndpoint(name), safeMemberRequest{
+			MemberName:  m.MemberName,
+			SearchIn:    valueOr(m.SearchIn, "Vault"),
+			MemberType:  m.MemberType,
+			Permissions: m.Permissions,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("add member %s: %w", m.MemberName, err))
+			continue
+		}
+		fmt.Printf("  Added member %s\n", m.MemberName)
+	}
+	for _, m := range plan.update {
+		endpoint := safeMembersEndpoint(name) + "/" + url.PathEscape(m.MemberName)
+		if _, err := client.Put(endpoint, map[string]interface{}{"permissions": m.Permissions}); err != nil {
+			errs = append(errs, fmt.Errorf("update member %s: %w", m.MemberName, err))
+			continue
+		}
+		fmt.Printf("  Updated member %s\n", m.MemberName)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportSafesSendsOneRetentionPolicy(t *testing.T) {
+	var created map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &created)
+		}
+		w.Write([]byte(`{"value":[],"count":0}`))
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "safes.json")
+	snapshot := `[{"safeName":"Linux","numberOfVersionsRetention":5,"numberOfDaysRetention":7,"members":[]}]`
+	if err := os.WriteFile(file, []byte(snapshot), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&ImportSafesWorkflow{}).Execute(newTestClient(t, srv), []string{"--file", file}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := created["numberOfDaysRetention"]; ok || created["numberOfVersionsRetention"] != 5.0 {
+		t.Errorf("created safe %v, want only numberOfVersionsRetention", created)
+	}
+
+	// A recorded create-safe must be one the flags accept.
+	days, versions := 7, 5
+	safe := Safe{SafeName: "Linux", NumberOfVersionsRetention: &versions, NumberOfDaysRetention: &days}
+	fs := flag.NewFlagSet("create-safe", flag.ContinueOnError)
+	var props safeFlags
+	props.register(fs)
+	if err := fs.Parse(safeArgs(safe)); err != nil {
+		t.Fatal(err)
+	}
+	if err := props.apply(fs, &Safe{}); err != nil {
+		t.Errorf("replaying %v: %v", safeArgs(safe), err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGrantSafeAccessAddsEachMemberOnce(t *testing.T) {
+	var mu sync.Mutex
+	var added []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var body safeMemberRequest
+			json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			added = append(added, body.MemberName)
+			mu.Unlock()
+		}
+		w.Write([]byte(`{"value":[],"count":0}`))
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "members")
+	if err := os.WriteFile(file, []byte("ALICE\nbob\ncarol\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"--safe", "Linux", "--role", "use", "--members", "alice,Bob,alice", "--members-file", file}
+	if err := (&GrantSafeAccessWorkflow{}).Execute(newTestClient(t, srv), args); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(added)
+	if got := strings.Join(added, ","); got != "Bob,alice,carol" {
+		t.Errorf("added %s, want each member once as first given", got)
+	}
+}
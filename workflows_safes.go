@@ -0,0 +1,419 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// Safe is a safe object as returned by the Safes API.
+type Safe struct {
+	SafeURLID                 string `json:"safeUrlId,omitempty"`
+	SafeName                  string `json:"safeName"`
+	SafeNumber                int    `json:"safeNumber,omitempty"`
+	Description               string `json:"description,omitempty"`
+	Location                  string `json:"location,omitempty"`
+	OLACEnabled               bool   `json:"olacEnabled"`
+	ManagingCPM               string `json:"managingCPM,omitempty"`
+	NumberOfVersionsRetention *int   `json:"numberOfVersionsRetention,omitempty"`
+	NumberOfDaysRetention     *int   `json:"numberOfDaysRetention,omitempty"`
+}
+
+// safeEndpoint returns the endpoint of a single safe.
+func safeEndpoint(safe string) string {
+	return "PasswordVault/API/Safes/" + url.PathEscape(safe)
+}
+
+// listSafes returns every safe visible to the caller.
+func listSafes(client *APIClient) ([]Safe, error) {
+	return fetchAll[Safe](client, "PasswordVault/API/Safes", nil)
+}
+
+// getSafe fetches a safe by name.
+func getSafe(client *APIClient, name string) (*Safe, error) {
+	safe, err := GetInto[Safe](client, safeEndpoint(name))
+	if err != nil {
+		return nil, err
+	}
+	return &safe, nil
+}
+
+// ListSafesWorkflow lists the safes visible to the user.
+type ListSafesWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("list-safes", &ListSafesWorkflow{})
+}
+
+// listSafesFlags are the flags of list-safes.
+type listSafesFlags struct {
+	search string
+	output outputOptions
+	checks resultChecks
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *listSafesFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("list-safes", "[--search TEXT] [--output FORMAT] [--fail-if-empty|--fail-if-nonempty]")
+	fs.StringVar(&f.search, "search", "", "only list safes whose name or description contains these words")
+	f.output.registerFlags(fs)
+	f.checks.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ListSafesWorkflow) Synopsis() string {
+	return "list the safes visible to you"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ListSafesWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(listSafesFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ListSafesWorkflow) Execute(client *APIClient, args []string) error {
+	f := listSafesFlags{output: outputOptions{Format: client.outputFormat}}
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	columns := []column[Safe]{
+		{"NAME", func(s Safe) string { return s.SafeName }},
+		{"DESCRIPTION", func(s Safe) string { return valueOr(s.Description, "-") }},
+		{"MANAGING CPM", func(s Safe) string { return valueOr(s.ManagingCPM, "-") }},
+		{"RETENTION", func(s Safe) string { return safeRetention(s) }},
+	}
+	r, err := newRenderer(f.output, os.Stdout, columns)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	if f.search != "" {
+		params.Set("search", f.search)
+	}
+	found := 0
+	err = fetchPages(client, "PasswordVault/API/Safes", params, func(page []Safe) error {
+		found += len(page)
+		return r.write(page)
+	})
+	if ferr := r.finish(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list safes: %w", err)
+	}
+	if found == 0 {
+		fmt.Fprintln(os.Stderr, "No safes found")
+	}
+	return f.checks.check(found)
+}
+
+// safeRetention describes a safe's retention policy for list-safes.
+func safeRetention(s Safe) string {
+	switch {
+	case s.NumberOfVersionsRetention != nil:
+		return fmt.Sprintf("%d versions", *s.NumberOfVersionsRetention)
+	case s.NumberOfDaysRetention != nil:
+		return fmt.Sprintf("%d days", *s.NumberOfDaysRetention)
+	}
+	return "-"
+}
+
+// safeFlags are the editable safe properties shared by create-safe and
+// update-safe.
+type safeFlags struct {
+	description   string
+	location      string
+	managingCPM   string
+	olac          bool
+	retention     int
+	retentionDays int
+}
+
+func (f *safeFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.description, "description", "", "safe description")
+	fs.StringVar(&f.location, "location", "", "vault location of the safe")
+	fs.StringVar(&f.managingCPM, "managing-cpm", "", "CPM that manages the safe's accounts")
+	fs.BoolVar(&f.olac, "olac", false, "enable object level access control")
+	fs.IntVar(&f.retention, "retention", 0, "number of password versions to retain")
+	fs.IntVar(&f.retentionDays, "retention-days", 0, "number of days to retain password versions")
+}
+
+// apply copies the flags that were set on the command line onto safe.
+func (f *safeFlags) apply(fs *flag.FlagSet, safe *Safe) error {
+	set := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	if set["retention"] && set["retention-days"] {
+		return errors.New("--retention and --retention-days are mutually exclusive")
+	}
+
+	if set["description"] {
+		safe.Description = f.description
+	}
+	if set["location"] {
+		safe.Location = f.location
+	}
+	if set["managing-cpm"] {
+		safe.ManagingCPM = f.managingCPM
+	}
+	if set["olac"] {
+		safe.OLACEnabled = f.olac
+	}
+	// The API accepts only one retention policy at a time.
+	if set["retention"] {
+		safe.NumberOfVersionsRetention, safe.NumberOfDaysRetention = &f.retention, nil
+	}
+	if set["retention-days"] {
+		safe.NumberOfDaysRetention, safe.NumberOfVersionsRetention = &f.retentionDays, nil
+	}
+	return nil
+}
+
+// oneRetentionPolicy clears the day-based retention of a safe that has
+// both policies set. The API accepts only one at a time, so this keeps the
+// version count, as apply does when --retention is given.
+func oneRetentionPolicy(safe *Safe) {
+	if safe.NumberOfVersionsRetention != nil {
+		safe.NumberOfDaysRetention = nil
+	}
+}
+
+// safeArgs returns the safeFlags command-line arguments that reproduce the
+// properties of safe.
+func safeArgs(safe Safe) []string {
+	var args []string
+	if safe.Description != "" {
+		args = append(args, "--description", safe.Description)
+	}
+	if safe.Location != "" {
+		args = append(args, "--location", safe.Location)
+	}
+	if safe.ManagingCPM != "" {
+		args = append(args, "--managing-cpm", safe.ManagingCPM)
+	}
+	args = append(args, "--olac="+strconv.FormatBool(safe.OLACEnabled))
+	switch {
+	case safe.NumberOfVersionsRetention != nil:
+		args = append(args, "--retention", strconv.Itoa(*safe.NumberOfVersionsRetention))
+	case safe.NumberOfDaysRetention != nil:
+		args = append(args, "--retention-days", strconv.Itoa(*safe.NumberOfDaysRetention))
+	}
+	return args
+}
+
+// CreateSafeWorkflow creates a safe.
+type CreateSafeWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("create-safe", &CreateSafeWorkflow{})
+}
+
+// createSafeFlags are the flags of create-safe.
+type createSafeFlags struct {
+	name    string
+	props   safeFlags
+	preview bodyPreview
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *createSafeFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("create-safe", "--name NAME [options]")
+	fs.StringVar(&f.name, "name", "", "safe name (required)")
+	f.props.register(fs)
+	f.preview.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *CreateSafeWorkflow) Synopsis() string {
+	return "create a safe"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *CreateSafeWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(createSafeFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *CreateSafeWorkflow) Execute(client *APIClient, args []string) error {
+	var f createSafeFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.name == "" {
+		fs.Usage()
+		return errors.New("--name is required")
+	}
+
+	body := Safe{SafeName: f.name}
+	if err := f.props.apply(fs, &body); err != nil {
+		return err
+	}
+
+	const endpoint = "PasswordVault/API/Safes"
+	if send, err := f.preview.show(client, http.MethodPost, endpoint, body); !send || err != nil {
+		return err
+	}
+	if _, err := client.Post(endpoint, body); err != nil {
+		return fmt.Errorf("failed to create safe %s: %w", f.name, err)
+	}
+	fmt.Printf("Created safe %s\n", f.name)
+	return nil
+}
+
+// UpdateSafeWorkflow changes the properties of an existing safe.
+type UpdateSafeWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("update-safe", &UpdateSafeWorkflow{})
+}
+
+// updateSafeFlags are the flags of update-safe.
+type updateSafeFlags struct {
+	name    string
+	props   safeFlags
+	preview bodyPreview
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *updateSafeFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("update-safe", "--name NAME [options]")
+	fs.StringVar(&f.name, "name", "", "safe to update (required)")
+	f.props.register(fs)
+	f.preview.registerFlags(fs)
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *UpdateSafeWorkflow) Synopsis() string {
+	return "change the properties of a safe"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *UpdateSafeWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(updateSafeFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *UpdateSafeWorkflow) Execute(client *APIClient, args []string) error {
+	var f updateSafeFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.name == "" {
+		fs.Usage()
+		return errors.New("--name is required")
+	}
+
+	// The update replaces the safe definition, so start from the current
+	// one to leave unspecified properties unchanged.
+	safe, err := getSafe(client, f.name)
+	if err != nil {
+		return fmt.Errorf("failed to read safe %s: %w", f.name, err)
+	}
+	if err := f.props.apply(fs, safe); err != nil {
+		return err
+	}
+	body := *safe
+	body.SafeURLID, body.SafeNumber = "", 0
+
+	endpoint := safeEndpoint(f.name)
+	if send, err := f.preview.show(client, http.MethodPut, endpoint, body); !send || err != nil {
+		return err
+	}
+	if _, err := client.Put(endpoint, body); err != nil {
+		return fmt.Errorf("failed to update safe %s: %w", f.name, err)
+	}
+	fmt.Printf("Updated safe %s\n", f.name)
+	return nil
+}
+
+// DeleteSafeWorkflow deletes a safe. The vault refuses while the safe
+// still holds accounts, and keeps a deleted safe's name reserved until its
+// retention period has passed.
+type DeleteSafeWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("delete-safe", &DeleteSafeWorkflow{})
+}
+
+// deleteSafeFlags are the flags of delete-safe.
+type deleteSafeFlags struct {
+	name string
+	yes  bool
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *deleteSafeFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("delete-safe", "--name NAME [--confirm]")
+	fs.StringVar(&f.name, "name", "", "safe to delete (required)")
+	fs.BoolVar(&f.yes, "confirm", false, "skip the confirmation prompt")
+	fs.BoolVar(&f.yes, "yes", false, "same as --confirm")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *DeleteSafeWorkflow) Synopsis() string {
+	return "delete an empty safe"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *DeleteSafeWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(deleteSafeFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *DeleteSafeWorkflow) Execute(client *APIClient, args []string) error {
+	var f deleteSafeFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if f.name == "" {
+		fs.Usage()
+		return errors.New("--name is required")
+	}
+
+	safe, err := getSafe(client, f.name)
+	if err != nil {
+		if isNotFound(err) {
+			return fmt.Errorf("safe %s not found", f.name)
+		}
+		return fmt.Errorf("failed to read safe %s: %w", f.name, err)
+	}
+	if err := client.precheck(safeNeed{safe.SafeName, []string{"manageSafe"}}); err != nil {
+		return err
+	}
+
+	id := safe.SafeURLID
+	if id == "" {
+		id = safe.SafeName
+	}
+	if !f.yes {
+		ok, err := confirm(fmt.Sprintf("Delete safe %s?", safe.SafeName))
+		if err != nil {
+			return fmt.Errorf("%w (pass --confirm to confirm)", err)
+		}
+		if !ok {
+			return errors.New("aborted")
+		}
+	}
+
+	if _, err := client.Delete(safeEndpoint(id)); err != nil {
+		if apiStatus(err) == http.StatusForbidden {
+			return fmt.Errorf("not allowed to delete safe %s: the Manage safe permission is required", safe.SafeName)
+		}
+		return fmt.Errorf("failed to delete safe %s: %w", safe.SafeName, err)
+	}
+	fmt.Printf("Deleted safe %s\n", safe.SafeName)
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// User holds the user fields the workflows report on.
+type User struct {
+	ID         int    `json:"id"`
+	Username   string `json:"username"`
+	Suspended  bool   `json:"suspended"`
+	EnableUser bool   `json:"enableUser"`
+
+	// GroupsMembership is only returned by GET /Users/{id}.
+	GroupsMembership []struct {
+		GroupName string `json:"groupName"`
+	} `json:"groupsMembership,omitempty"`
+}
+
+// getUser fetches a user by ID.
+func getUser(client *APIClient, id int) (*User, error) {
+	user, err := GetInto[User](client, "PasswordVault/API/Users/"+strconv.Itoa(id))
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// findUser looks a user up by exact username, ignoring case.
+func findUser(client *APIClient, username string) (*User, error) {
+	result, err := GetInto[struct {
+		Users []User `json:"Users"`
+	}](client, "PasswordVault/API/Users?search="+url.QueryEscape(username))
+	if err != nil {
+		return nil, err
+	}
+	for i := range result.Users {
+		if strings.EqualFold(result.Users[i].Username, username) {
+			return &result.Users[i], nil
+		}
+	}
+	return nil, fmt.Errorf("user %q not found", username)
+}
+
+// ActivateUserWorkflow reactivates a suspended user.
+type ActivateUserWorkflow struct{}
+
+func init() {
+	RegisterWorkflow("activate-user", &ActivateUserWorkflow{})
+}
+
+// activateUserFlags are the flags of activate-user.
+type activateUserFlags struct {
+	id       int
+	username string
+	yes      bool
+}
+
+// flagSet returns the flag set that parses into f.
+func (f *activateUserFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("activate-user", "(--id ID | --username NAME) [--confirm]")
+	fs.IntVar(&f.id, "id", 0, "ID of the user to activate")
+	fs.StringVar(&f.username, "username", "", "name of the user to activate")
+	fs.BoolVar(&f.yes, "confirm", false, "skip the confirmation prompt")
+	fs.BoolVar(&f.yes, "yes", false, "same as --confirm")
+	return fs
+}
+
+// Synopsis implements DescribableWorkflow.
+func (w *ActivateUserWorkflow) Synopsis() string {
+	return "reactivate a suspended user"
+}
+
+// FlagSpecs implements DescribableWorkflow.
+func (w *ActivateUserWorkflow) FlagSpecs() []FlagSpec {
+	return flagSpecs(new(activateUserFlags).flagSet())
+}
+
+// Execute implements Workflow.
+func (w *ActivateUserWorkflow) Execute(client *APIClient, args []string) error {
+	var f activateUserFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (f.id == 0) == (f.username == "") {
+		fs.Usage()
+		return errors.New("exactly one of --id or --username is required")
+	}
+
+	if f.username != "" {
+		user, err := findUser(client, f.username)
+		if err != nil {
+			return err
+		}
+		f.id = user.ID
+	}
+	target := fmt.Sprintf("user %d", f.id)
+	if f.username != "" {
+		target = fmt.Sprintf("user %s (id %d)", f.username, f.id)
+	}
+
+	if !f.yes {
+		ok, err := confirm(fmt.Sprintf("Reactivate %s? This lets a suspended user log on again.", target))
+		if err != nil {
+			return fmt.Errorf("%w (pass --confirm to confirm)", err)
+		}
+		if !ok {
+			return errors.New("aborted")
+		}
+	}
+
+	if _, err := client.Post(fmt.Sprintf("PasswordVault/API/Users/%d/Activate", f.id), nil); err != nil {
+		switch apiStatus(err) {
+		case http.StatusForbidden:
+			return fmt.Errorf("not allowed to activate %s: the Activate Users vault authorization is required", target)
+		case http.StatusNotFound:
+			return fmt.Errorf("%s not found", target)
+		}
+		return fmt.Errorf("failed to activate %s: %w", target, err)
+	}
+
+	user, err := getUser(client, f.id)
+	if err != nil {
+		fmt.Printf("Activated %s\n", target)
+		return fmt.Errorf("activated, but could not read back the user's state: %w", err)
+	}
+	fmt.Printf("Activated %s: enabled=%t suspended=%t\n", target, user.EnableUser, user.Suspended)
+	return nil
+}